@@ -8,8 +8,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"sim"
@@ -18,6 +20,15 @@ import (
 var runCount = flag.Int("n", 1000, "Number of Monte Carlo simulations")
 var configFile = flag.String("config", "-", "Configuration file")
 var resultsDir = flag.String("output", "results", "Output directory")
+var workerCount = flag.Int("workers", runtime.NumCPU(), "Number of simulations to run concurrently")
+var masterSeed = flag.Int64("seed", 0, "Master random seed (overrides config)")
+var seedFile = flag.String("seed-file", "", "Derive random seeds from the contents of this file instead of the master seed")
+var startDate = flag.String("start", "", "Simulation start date, YYYY-MM-DD (overrides config)")
+var taxTables = flag.String("tax-tables", "", "JSON file of additional tax tables")
+var dollars = flag.String("dollars", "nominal", "Dollars the balance files are written in: nominal, or real (today's dollars)")
+var historyFile = flag.String("history", "", "CSV file of monthly or annual market history to replace the bundled annual returns")
+var economyModel = flag.String("economy", "", "Market model (overrides config): " + strings.Join(sim.EconomyModels, ", "))
+var withdrawalStrategy = flag.String("withdrawal-strategy", "", "How investments are drawn down (overrides config): " + strings.Join(sim.WithdrawalStrategies, ", "))
 
 type Transaction struct {
 	Date time.Time `json:"date"`
@@ -58,6 +69,7 @@ func (x *IndexedFloats) Less(i, j int) bool {
 type Result struct {
 	Age *IndexedFloat `json:"age"`
 	Balance *IndexedFloat `json:"balance"`
+	RealBalance *IndexedFloat `json:"real_balance"`
 	Market *IndexedFloat `json:"market"`
 }
 
@@ -68,6 +80,7 @@ type Results struct {
 	Bankruptcies []int `json:"bankruptcies"`
 	Runs []*sim.Results `json:"runs"`
 	Start float64 `json:"start"`
+	RealStart float64 `json:"real_start"`
 	Worst *Result `json:"worst"`
 	Worst95 *Result `json:"worst95"`
 	Worst75 *Result `json:"worst75"`
@@ -91,7 +104,7 @@ func writeBalances(res *sim.Results) error {
 		accts = append(accts, k)
 	}
 	sort.Strings(accts)
-	header := append([]string{"date"}, accts...)
+	header := append([]string{"date", "price_index", "spending"}, accts...)
 	err = w.Write(header)
 	if err != nil {
 		fmt.Println("error writing results header:", err)
@@ -99,13 +112,18 @@ func writeBalances(res *sim.Results) error {
 	}
 	crow := make([]string, len(header))
 	for _, row := range res.Balances {
+		if *dollars == "real" {
+			row = row.Real()
+		}
 		crow[0] = row.Date
+		crow[1] = strconv.FormatFloat(row.PriceIndex, 'f', 6, 64)
+		crow[2] = strconv.FormatFloat(row.Spending, 'f', 2, 64)
 		for j, k := range accts {
 			v, ok := row.Balances[k]
 			if ok {
-				crow[j+1] = strconv.FormatFloat(v, 'f', 2, 64)
+				crow[j+3] = strconv.FormatFloat(v, 'f', 2, 64)
 			} else {
-				crow[j+1] = ""
+				crow[j+3] = ""
 			}
 		}
 		err = w.Write(crow)
@@ -191,6 +209,10 @@ func writeTransactions(res *sim.Results) error {
 
 func main() {
 	flag.Parse()
+	if *dollars != "nominal" && *dollars != "real" {
+		fmt.Println("error: -dollars must be nominal or real")
+		return
+	}
 	var err error
 	var cfgBytes []byte
 	if *configFile == "-" {
@@ -202,72 +224,109 @@ func main() {
 		fmt.Println("error reading input:", err)
 		return
 	}
+	if *taxTables != "" {
+		err = sim.LoadTaxTables(*taxTables)
+		if err != nil {
+			fmt.Println("error loading tax tables:", err)
+			return
+		}
+	}
+	if *historyFile != "" {
+		err = sim.LoadHistory(*historyFile)
+		if err != nil {
+			fmt.Println("error loading market history:", err)
+			return
+		}
+	}
 	cfg := &sim.SimConfig{}
 	err = json.Unmarshal(cfgBytes, cfg)
 	if err != nil {
 		fmt.Println("error parsing config:", err)
 		return
 	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			cfg.Seed = *masterSeed
+		case "seed-file":
+			cfg.SeedFile = *seedFile
+		case "start":
+			cfg.StartDate = *startDate
+		case "economy":
+			if cfg.Economy == nil {
+				cfg.Economy = &sim.EconomyConfig{}
+			}
+			cfg.Economy.Model = *economyModel
+		case "withdrawal-strategy":
+			cfg.WithdrawalStrategy = *withdrawalStrategy
+		}
+	})
+	err = cfg.Validate()
+	if err != nil {
+		fmt.Println("invalid config:")
+		fmt.Println(err)
+		return
+	}
+	// pin every run to the same clock, even if the month rolls over
+	// while they're running
+	cfg.StartDate = cfg.Start().Format("2006-01-02")
+	if cfg.SeedFile != "" {
+		pod, err := sim.NewSeedPod(cfg.SeedFile, 0)
+		if err != nil {
+			fmt.Println("error opening seed file:", err)
+			return
+		}
+		pod.Close()
+	}
 	n := *runCount
 	runs := make([]*sim.Results, n)
 	mean := &sim.Results{}
 	age := NewIndexedFloats()
 	cash := NewIndexedFloats()
+	realCash := NewIndexedFloats()
 	market := NewIndexedFloats()
 	nf := float64(n)
-	var start float64
+	var start, realStart float64
 	err = os.MkdirAll(*resultsDir, os.FileMode(0777))
 	if err != nil {
 		fmt.Println("error creating results directory", *resultsDir, err)
 		return
 	}
+	summaries, err := runSimulations(n, *workerCount, cfg)
+	if err != nil {
+		fmt.Println("error running simulations:", err)
+		os.Exit(1)
+	}
 	earlyDeaths := []int{}
 	liquidityCrises := []int{}
 	bankruptcies := []int{}
-	for i := 0; i < n; i++ {
-		os.Stderr.WriteString(fmt.Sprintf("\rsim run %d", i))
-		s := sim.NewSimulation(i, cfg)
-		start = s.Balance() - s.Liabilities(s.StartDate())
-		res := s.Results()
-		res.Index = i
-		mean.Age += res.Age / nf
-		mean.Balance += res.Balance / nf
-		mean.Market += res.Market / nf
+	for i, rs := range summaries {
+		start = rs.Start
+		realStart = rs.RealStart
+		mean.Age += rs.Age / nf
+		mean.Balance += rs.Balance / nf
+		mean.RealBalance += rs.RealBalance / nf
+		mean.Market += rs.Market / nf
 		runs[i] = &sim.Results{
 			Index: i,
-			Age: res.Age,
-			Balance: res.Balance,
-			Market: res.Market,
+			Age: rs.Age,
+			Balance: rs.Balance,
+			RealBalance: rs.RealBalance,
+			Market: rs.Market,
 		}
-		age.Add(res.Age)
-		cash.Add(res.Balance)
-		market.Add(res.Market)
-		if res.Balance <= 0.0 {
-			os.Stderr.WriteString(fmt.Sprintf("\rsim run %d BUSTED                              \n", i))
-		}
-		if res.Age < s.RetirementAge() {
+		age.Add(rs.Age)
+		cash.Add(rs.Balance)
+		realCash.Add(rs.RealBalance)
+		market.Add(rs.Market)
+		if rs.EarlyDeath {
 			earlyDeaths = append(earlyDeaths, i)
 		}
-		if res.Events.Has("Liquidity Crisis") {
+		if rs.LiquidityCrisis {
 			liquidityCrises = append(liquidityCrises, i)
 		}
-		if res.Events.Has("Bankruptcy") {
+		if rs.Bankruptcy {
 			bankruptcies = append(bankruptcies, i)
 		}
-		err := writeBalances(res)
-		if err != nil {
-			return
-		}
-		/*
-		err = writeTransactions(res)
-		if err != nil {
-			return
-		}
-		*/
-		err = writeEvents(res)
-		if err != nil {
-			return
-		}
 	}
 	os.Stderr.WriteString("\nSummarizing...")
 	mid := n / 2
@@ -277,6 +336,7 @@ func main() {
 	max95 := 19 * n / 20
 	sort.Sort(age)
 	sort.Sort(cash)
+	sort.Sort(realCash)
 	sort.Sort(market)
 	res := &Results{
 		Count: *runCount,
@@ -285,40 +345,48 @@ func main() {
 		Bankruptcies: bankruptcies,
 		Runs: runs,
 		Start: start,
+		RealStart: realStart,
 		Mean: mean,
 		Best: &Result{
 			Age: (*age)[n-1],
 			Balance: (*cash)[n-1],
+			RealBalance: (*realCash)[n-1],
 			Market: (*market)[n-1],
 		},
 		Best95: &Result{
 			Age: (*age)[max95],
 			Balance: (*cash)[max95],
+			RealBalance: (*realCash)[max95],
 			Market: (*market)[max95],
 		},
 		Best75: &Result{
 			Age: (*age)[max75],
 			Balance: (*cash)[max75],
+			RealBalance: (*realCash)[max75],
 			Market: (*market)[max75],
 		},
 		Median: &Result{
 			Age: (*age)[mid],
 			Balance: (*cash)[mid],
+			RealBalance: (*realCash)[mid],
 			Market: (*market)[mid],
 		},
 		Worst75: &Result{
 			Age: (*age)[min75],
 			Balance: (*cash)[min75],
+			RealBalance: (*realCash)[min75],
 			Market: (*market)[min75],
 		},
 		Worst95: &Result{
 			Age: (*age)[min95],
 			Balance: (*cash)[min95],
+			RealBalance: (*realCash)[min95],
 			Market: (*market)[min95],
 		},
 		Worst: &Result{
 			Age: (*age)[0],
 			Balance: (*cash)[0],
+			RealBalance: (*realCash)[0],
 			Market: (*market)[0],
 		},
 	}
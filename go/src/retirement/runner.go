@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"sim"
+)
+
+// RunSummary is what a worker hands back for each simulation.  The
+// full results are written to disk by the worker, so only the numbers
+// needed for the index are kept in memory.
+type RunSummary struct {
+	Index int
+	Start float64
+	RealStart float64
+	Age float64
+	Balance float64
+	RealBalance float64
+	Market float64
+	EarlyDeath bool
+	LiquidityCrisis bool
+	Bankruptcy bool
+	err error
+}
+
+func runSimulation(i int, cfg *sim.SimConfig) *RunSummary {
+	s, err := sim.NewSimulation(i, cfg)
+	if err != nil {
+		return &RunSummary{Index: i, err: err}
+	}
+	start := s.Balance() - s.Liabilities(s.StartDate())
+	realStart := start / s.Economy.PriceIndex(s.StartDate())
+	res := s.Results()
+	res.Index = i
+	rs := &RunSummary{
+		Index: i,
+		Start: start,
+		RealStart: realStart,
+		Age: res.Age,
+		Balance: res.Balance,
+		RealBalance: res.RealBalance,
+		Market: res.Market,
+		EarlyDeath: res.Age < s.RetirementAge(),
+		LiquidityCrisis: res.Events.Has("Liquidity Crisis"),
+		Bankruptcy: res.Events.Has("Bankruptcy"),
+	}
+	rs.err = writeBalances(res)
+	if rs.err != nil {
+		return rs
+	}
+	/*
+	rs.err = writeTransactions(res)
+	if rs.err != nil {
+		return rs
+	}
+	*/
+	rs.err = writeEvents(res)
+	return rs
+}
+
+// runSimulations spreads n simulations across a pool of workers.  Each
+// simulation is seeded from its own index, so the summaries come back
+// in index order no matter which worker ran them or when it finished.
+// The first error stops the pool, and waits for the workers to finish
+// the runs they'd started.
+func runSimulations(n, workers int, cfg *sim.SimConfig) ([]*RunSummary, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	done := make(chan *RunSummary, workers)
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case done <- runSimulation(i, cfg):
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case jobs <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	summaries := make([]*RunSummary, n)
+	for k := 0; k < n; k++ {
+		rs := <-done
+		if rs.err != nil {
+			close(quit)
+			wg.Wait()
+			return nil, rs.err
+		}
+		summaries[rs.Index] = rs
+		os.Stderr.WriteString(fmt.Sprintf("\rsim run %d", k))
+		if rs.Balance <= 0.0 {
+			os.Stderr.WriteString(fmt.Sprintf("\rsim run %d BUSTED                              \n", rs.Index))
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,168 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCorrelations is how month-to-month surprises in the regime
+// model's stocks, bonds and bills move together when economy.correlations
+// isn't set.
+var DefaultCorrelations = &CorrelationConfig{StocksBonds: 0.1, StocksCash: 0.0, BondsCash: 0.2}
+
+// Matrix returns the correlations between stocks, bonds and cash, in
+// that order.
+func (c *CorrelationConfig) Matrix() [][]float64 {
+	return [][]float64{
+		{1.0, c.StocksBonds, c.StocksCash},
+		{c.StocksBonds, 1.0, c.BondsCash},
+		{c.StocksCash, c.BondsCash, 1.0},
+	}
+}
+
+// cholesky factors a correlation matrix into a lower triangle l with
+// l times its transpose equal to m, so l turns independent normal draws
+// into correlated ones.
+func cholesky(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0.0 {
+					return nil, errors.New("Correlations are not positive definite")
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// assetReturns draws bonds and bills for every month the regime model
+// covers.  Each month's stock surprise, how far the return landed from
+// its regime's mean, is the first of the correlated draws, so the stock
+// market is the same one it would be without bonds and bills.  They're
+// drawn after inflation, so that doesn't change either.
+func (m *RegimeModel) assetReturns() {
+	if m.bonds != nil {
+		return
+	}
+	rets := m.MarketReturns()
+	m.InflationRates()
+	corr := m.correlations
+	if corr == nil {
+		corr = DefaultCorrelations
+	}
+	l, err := cholesky(corr.Matrix())
+	if err != nil {
+		// validation keeps this from happening
+		l, _ = cholesky(DefaultCorrelations.Matrix())
+	}
+	m.bonds = make([]float64, len(rets))
+	m.bills = make([]float64, len(rets))
+	var last *Regime
+	var bondMean, bondVol, billMean, billVol float64
+	for i, r := range m.regimes {
+		if r != last {
+			s := r.state
+			bondMean = m.ClampedGauss(s.BondReturn.mean, s.BondReturn.stddev, s.BondReturn.min, s.BondReturn.max)
+			bondVol = m.ClampedGauss(s.BondVolatility.mean, s.BondVolatility.stddev, s.BondVolatility.min, s.BondVolatility.max)
+			billMean = m.ClampedGauss(s.BillReturn.mean, s.BillReturn.stddev, s.BillReturn.min, s.BillReturn.max)
+			billVol = m.ClampedGauss(s.BillVolatility.mean, s.BillVolatility.stddev, s.BillVolatility.min, s.BillVolatility.max)
+			last = r
+		}
+		zs := 0.0
+		if r.Volatility() > 0.0 {
+			zs = (rets[i] - r.MeanReturn()) / r.Volatility()
+		}
+		z1 := m.Gauss(0.0, 1.0)
+		z2 := m.Gauss(0.0, 1.0)
+		m.bonds[i] = bondMean + bondVol * (l[1][0] * zs + l[1][1] * z1)
+		m.bills[i] = billMean + billVol * (l[2][0] * zs + l[2][1] * z1 + l[2][2] * z2)
+	}
+}
+
+func (m *RegimeModel) BondReturns() []float64 {
+	m.assetReturns()
+	return m.bonds
+}
+
+func (m *RegimeModel) BillReturns() []float64 {
+	m.assetReturns()
+	return m.bills
+}
+
+// Allocation splits a portfolio between stocks, bonds and cash.  One
+// that's all zero instead scales the stock market by Risk, the way
+// portfolios worked before there were asset classes.
+type Allocation struct {
+	Stocks float64
+	Bonds float64
+	Cash float64
+	Risk float64
+}
+
+func (a *Allocation) IsRisk() bool {
+	return a.Stocks == 0.0 && a.Bonds == 0.0 && a.Cash == 0.0
+}
+
+// AllocationConfig is the shares of a portfolio in stocks, bonds and
+// cash.  It can also be written as percentages like "60/40" or
+// "60/30/10", or as a bare number, the old risk multiplier.
+type AllocationConfig struct {
+	Stocks float64 `json:"stocks"`
+	Bonds float64 `json:"bonds"`
+	Cash float64 `json:"cash"`
+	risk float64
+	isRisk bool
+}
+
+func (c *AllocationConfig) UnmarshalJSON(data []byte) error {
+	var risk float64
+	if json.Unmarshal(data, &risk) == nil {
+		*c = AllocationConfig{risk: risk, isRisk: true}
+		return nil
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		parts := strings.Split(s, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return errors.New("Allocation " + strconv.Quote(s) + " is not stocks/bonds or stocks/bonds/cash")
+		}
+		shares := make([]float64, 3)
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return errors.New("Allocation " + strconv.Quote(s) + " is not stocks/bonds or stocks/bonds/cash")
+			}
+			shares[i] = v / 100.0
+		}
+		*c = AllocationConfig{Stocks: shares[0], Bonds: shares[1], Cash: shares[2]}
+		return nil
+	}
+	type plainAllocation AllocationConfig
+	return json.Unmarshal(data, (*plainAllocation)(c))
+}
+
+func (c *AllocationConfig) Allocation() *Allocation {
+	if c == nil {
+		return &Allocation{}
+	}
+	if c.isRisk {
+		return &Allocation{Risk: c.risk}
+	}
+	return &Allocation{Stocks: c.Stocks, Bonds: c.Bonds, Cash: c.Cash}
+}
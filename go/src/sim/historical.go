@@ -0,0 +1,263 @@
+package sim
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed history.csv
+var historyCSV []byte
+
+// HistoricalMonth is one month of market history.  Like everything else
+// in the economy, returns and inflation are annualized percent.  Annual
+// is set when the month is an even share of a year's change.
+type HistoricalMonth struct {
+	Year int
+	Month int
+	Stocks float64
+	Bonds float64
+	Bills float64
+	Inflation float64
+	Annual bool
+}
+
+// History is the market history the historical and bootstrap models
+// draw from, oldest month first.  The bundled history is annual only;
+// monthly history has to be loaded with LoadHistory.
+var History []*HistoricalMonth
+
+func init() {
+	var err error
+	History, err = ParseHistory(historyCSV)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LoadHistory replaces the bundled market history with a file in the
+// format ParseHistory reads.
+func LoadHistory(fn string) error {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	history, err := ParseHistory(data)
+	if err != nil {
+		return err
+	}
+	History = history
+	return nil
+}
+
+// ParseHistory reads market history from CSV with year, stocks, bonds,
+// bills and inflation columns, in percent.  With a month column each
+// row is one month's change; without one each row is a year's, spread
+// evenly over its months.  Lines starting with # are ignored.
+func ParseHistory(data []byte) ([]*HistoricalMonth, error) {
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("No market history")
+	}
+	cols := map[string]int{}
+	for i, name := range rows[0] {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"year", "stocks", "bonds", "bills", "inflation"} {
+		if _, ok := cols[name]; !ok {
+			return nil, errors.New("Market history is missing the " + name + " column")
+		}
+	}
+	_, monthly := cols["month"]
+	history := []*HistoricalMonth{}
+	for _, row := range rows[1:] {
+		vals := map[string]float64{}
+		for name, i := range cols {
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+			if err != nil {
+				return nil, err
+			}
+			vals[name] = v
+		}
+		if monthly {
+			history = append(history, &HistoricalMonth{
+				Year: int(vals["year"]),
+				Month: int(vals["month"]),
+				Stocks: 12.0 * vals["stocks"],
+				Bonds: 12.0 * vals["bonds"],
+				Bills: 12.0 * vals["bills"],
+				Inflation: 12.0 * vals["inflation"],
+			})
+			continue
+		}
+		for m := 1; m <= 12; m++ {
+			history = append(history, &HistoricalMonth{
+				Year: int(vals["year"]),
+				Month: m,
+				Stocks: monthlyRate(vals["stocks"]),
+				Bonds: monthlyRate(vals["bonds"]),
+				Bills: monthlyRate(vals["bills"]),
+				Inflation: monthlyRate(vals["inflation"]),
+				Annual: true,
+			})
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Year != history[j].Year {
+			return history[i].Year < history[j].Year
+		}
+		return history[i].Month < history[j].Month
+	})
+	return history, nil
+}
+
+// monthlyRate spreads an annual percent change evenly over twelve months
+// and returns the monthly change as an annualized percent.
+func monthlyRate(annual float64) float64 {
+	return 1200.0 * (math.Pow(1.0 + annual / 100.0, 1.0 / 12.0) - 1.0)
+}
+
+// HistoricalSeries is a century of monthly market history, however it
+// was put together.
+type HistoricalSeries struct {
+	stocks []float64
+	bonds []float64
+	bills []float64
+	inflation []float64
+}
+
+func newHistoricalSeries() *HistoricalSeries {
+	return &HistoricalSeries{
+		stocks: make([]float64, 0, 1200),
+		bonds: make([]float64, 0, 1200),
+		bills: make([]float64, 0, 1200),
+		inflation: make([]float64, 0, 1200),
+	}
+}
+
+func (h *HistoricalSeries) add(m *HistoricalMonth) {
+	h.stocks = append(h.stocks, m.Stocks)
+	h.bonds = append(h.bonds, m.Bonds)
+	h.bills = append(h.bills, m.Bills)
+	h.inflation = append(h.inflation, m.Inflation)
+}
+
+func (h *HistoricalSeries) full() bool {
+	return len(h.stocks) >= 1200
+}
+
+func (h *HistoricalSeries) MarketReturns() []float64 {
+	return h.stocks
+}
+
+func (h *HistoricalSeries) BondReturns() []float64 {
+	return h.bonds
+}
+
+func (h *HistoricalSeries) BillReturns() []float64 {
+	return h.bills
+}
+
+func (h *HistoricalSeries) InflationRates() []float64 {
+	return h.inflation
+}
+
+// HistoricalModel replays market history starting from January of one
+// year, wrapping around to the oldest month after the newest.
+type HistoricalModel struct {
+	*HistoricalSeries
+	StartYear int
+}
+
+// NewHistoricalModel starts the nth simulation at startYear, or if
+// that's not set, at the nth year of history so a batch of runs steps
+// through every starting year in turn.
+func NewHistoricalModel(history []*HistoricalMonth, n, startYear int) *HistoricalModel {
+	starts := yearStarts(history)
+	start := starts[n % len(starts)]
+	for _, i := range starts {
+		if history[i].Year == startYear {
+			start = i
+		}
+	}
+	m := &HistoricalModel{
+		HistoricalSeries: newHistoricalSeries(),
+		StartYear: history[start].Year,
+	}
+	for i := start; !m.full(); i++ {
+		m.add(history[i % len(history)])
+	}
+	return m
+}
+
+// yearStarts returns where each year of history begins.
+func yearStarts(history []*HistoricalMonth) []int {
+	starts := []int{}
+	for i, m := range history {
+		if i == 0 || m.Year != history[i-1].Year {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// AnnualHistory reports whether history was spread from annual figures.
+func AnnualHistory(history []*HistoricalMonth) bool {
+	return len(history) > 0 && history[0].Annual
+}
+
+// How many months of history the bootstrap model draws at a time unless
+// configured otherwise.
+const DefaultBlockLength = 36
+
+// BootstrapModel builds a century from blocks of consecutive history
+// drawn at random, wrapping around at the end.  Stocks, bonds, bills and
+// inflation are drawn together, so their correlations survive.  Monthly
+// history is drawn in blocks of months starting in any month, which keep
+// its autocorrelation and fat tails.  Annual history has no swings within
+// a year to keep, so its blocks are rounded to whole calendar years
+// starting in January; every month of a year still sees the same return.
+type BootstrapModel struct {
+	*Simulacrum
+	*HistoricalSeries
+}
+
+// NewBootstrapModel draws blocks of block months, rounded to the nearest
+// whole year if the history is annual.
+func NewBootstrapModel(sc *Simulacrum, history []*HistoricalMonth, block int) *BootstrapModel {
+	m := &BootstrapModel{
+		Simulacrum: sc,
+		HistoricalSeries: newHistoricalSeries(),
+	}
+	if block <= 0 {
+		block = DefaultBlockLength
+	}
+	if AnnualHistory(history) {
+		block = 12 * int(math.Max(1, math.Round(float64(block) / 12)))
+		starts := yearStarts(history)
+		for !m.full() {
+			start := starts[m.Rng().Intn(len(starts))]
+			for j := 0; j < block && !m.full(); j++ {
+				m.add(history[(start + j) % len(history)])
+			}
+		}
+		return m
+	}
+	for !m.full() {
+		start := m.Rng().Intn(len(history))
+		for j := 0; j < block && !m.full(); j++ {
+			m.add(history[(start + j) % len(history)])
+		}
+	}
+	return m
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"math"
+)
+
+// InflationPersistence is how much of last month's deviation from the
+// regime's mean carries into this month.
+const InflationPersistence = 0.9
+
+// InflationRegime is a stretch of time with a characteristic rate of
+// inflation, in annualized percent.  Monthly inflation wanders around
+// the regime's mean with the given volatility.
+type InflationRegime struct {
+	name string
+	duration *gaussianInputs
+	mean *gaussianInputs
+	vol float64
+}
+
+var (
+	ModerateInflation = &InflationRegime{
+		name: "moderate",
+		duration: &gaussianInputs{mean: 60.0, stddev: 24.0, min: 24.0, max: 120.0},
+		mean: &gaussianInputs{mean: 2.5, stddev: 0.5, min: 1.5, max: 3.5},
+		vol: 0.75,
+	}
+	ElevatedInflation = &InflationRegime{
+		name: "elevated",
+		duration: &gaussianInputs{mean: 36.0, stddev: 12.0, min: 12.0, max: 60.0},
+		mean: &gaussianInputs{mean: 5.0, stddev: 1.0, min: 3.5, max: 7.0},
+		vol: 1.0,
+	}
+	HighInflation = &InflationRegime{
+		name: "high",
+		duration: &gaussianInputs{mean: 30.0, stddev: 12.0, min: 12.0, max: 72.0},
+		mean: &gaussianInputs{mean: 9.0, stddev: 2.0, min: 6.0, max: 14.0},
+		vol: 1.5,
+	}
+	Deflation = &InflationRegime{
+		name: "deflation",
+		duration: &gaussianInputs{mean: 18.0, stddev: 6.0, min: 6.0, max: 36.0},
+		mean: &gaussianInputs{mean: -1.0, stddev: 1.0, min: -4.0, max: 0.5},
+		vol: 0.75,
+	}
+)
+
+func (r *InflationRegime) Name() string {
+	return r.name
+}
+
+type inflationOdds struct {
+	regime *InflationRegime
+	weight float64
+}
+
+// inflationTransitions ties inflation to the market: when an inflation
+// regime ends, the next one is drawn with odds that depend on the
+// market regime at the time.  Stagnant and shrinking markets make
+// stagflation likely, depressions bring deflation, and booms run hot.
+var inflationTransitions = map[string][]inflationOdds{
+	"recovery": {{ModerateInflation, 0.7}, {ElevatedInflation, 0.3}},
+	"expansion": {{ModerateInflation, 0.65}, {ElevatedInflation, 0.3}, {HighInflation, 0.05}},
+	"bubble": {{ModerateInflation, 0.5}, {ElevatedInflation, 0.4}, {HighInflation, 0.1}},
+	"recession": {{ModerateInflation, 0.4}, {ElevatedInflation, 0.25}, {HighInflation, 0.25}, {Deflation, 0.1}},
+	"depression": {{ModerateInflation, 0.3}, {Deflation, 0.7}},
+	"stagnation": {{ModerateInflation, 0.35}, {ElevatedInflation, 0.25}, {HighInflation, 0.35}, {Deflation, 0.05}},
+}
+
+func (m *RegimeModel) nextInflationRegime(market *Regime) *InflationRegime {
+	if market == nil {
+		return ModerateInflation
+	}
+	odds, ok := inflationTransitions[market.Name()]
+	if !ok {
+		return ModerateInflation
+	}
+	r := m.Random()
+	for _, o := range odds {
+		if r < o.weight {
+			return o.regime
+		}
+		r -= o.weight
+	}
+	return odds[len(odds) - 1].regime
+}
+
+// InflationRates returns a century of monthly inflation, in annualized
+// percent.  It's drawn from the economy's own random numbers, so it
+// doesn't disturb the market or anything else in the simulation.
+func (m *RegimeModel) InflationRates() []float64 {
+	if m.inflation == nil {
+		m.MarketReturns()
+		infl := make([]float64, len(m.regimes))
+		regime := ModerateInflation
+		remaining := 0
+		mean := 2.5
+		x := 2.5
+		for i := range infl {
+			if remaining <= 0 {
+				if i > 0 {
+					regime = m.nextInflationRegime(m.regimes[i])
+				}
+				remaining = int(m.ClampedGauss(regime.duration.mean, regime.duration.stddev, regime.duration.min, regime.duration.max))
+				mean = m.ClampedGauss(regime.mean.mean, regime.mean.stddev, regime.mean.min, regime.mean.max)
+			}
+			x = mean + InflationPersistence * (x - mean) + m.Gauss(0.0, regime.vol)
+			infl[i] = math.Max(-10.0, math.Min(25.0, x))
+			remaining--
+		}
+		m.inflation = infl
+	}
+	return m.inflation
+}
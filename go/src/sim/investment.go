@@ -2,18 +2,39 @@ package sim
 
 import (
 	"errors"
+	"math"
 	"time"
 )
 
+// DividendYield is the share of a brokerage account's balance paid out
+// each year as qualified dividends.
+const DividendYield = 0.02
+
 type InvestmentAccount struct {
 	*Simulacrum
 	name string
 	ledger *Ledger
 	balance float64
+	basis float64
 	rmd RMD
 	canWithdraw WithdrawRule
 	canDeposit DepositRule
+	limit ContributionLimit
+	// accounts with the same limitName share their owner's annual limit
+	limitName string
+	withdrawTax WithdrawTax
+	sepp SEPPSchedule
+	dividendYield float64
+	// portfolio is set for accounts invested apart from the household
+	portfolio *Portfolio
+	// mix is how the account is actually invested, which drifts from
+	// the target between rebalances
+	mix *Allocation
 	taxable bool
+	taxFree bool
+	deductible bool
+	// payroll accounts only take contributions through payroll
+	payroll bool
 }
 
 func NewInvestmentAccount(sim *Simulation, balance float64, name string) *InvestmentAccount {
@@ -32,7 +53,10 @@ func NewInvestmentAccount(sim *Simulation, balance float64, name string) *Invest
 	a.canDeposit = func(date time.Time) bool {
 		return true
 	}
+	a.withdrawTax = CapitalGainsTax(sim)
+	a.dividendYield = DividendYield
 	a.Transaction(balance, a.StartDate(), OpenAccount)
+	a.basis = balance
 	return a
 }
 
@@ -46,20 +70,52 @@ func New401K(sim *Simulation, balance float64, name string) *InvestmentAccount {
 	a.rmd = K401RMD(sim)
 	a.canWithdraw = K401CanWithdraw(sim)
 	a.canDeposit = K401CanDeposit(sim)
+	a.limit = K401ContributionLimit(sim)
+	a.limitName = "401k"
+	a.payroll = true
+	a.withdrawTax = EarlyWithdrawalTax(sim, OrdinaryIncomeTax(sim), RuleOf55(sim))
+	a.Transaction(balance, a.StartDate(), OpenAccount)
+	return a
+}
+
+// NewIRA returns a traditional IRA owned by the simulated person.
+// Contributions are deductible up to the annual limit.  If seppAge is
+// set, a 72(t) schedule starting at that age allows penalty-free
+// withdrawals before 59½.
+func NewIRA(sim *Simulation, balance, seppAge float64, name string) *InvestmentAccount {
+	a := &InvestmentAccount{
+		Simulacrum: NewSimulacrum(sim),
+		name: name + " Traditional IRA",
+		ledger: NewLedger(),
+		taxable: true,
+		deductible: true,
+	}
+	a.rmd = K401RMD(sim)
+	a.canWithdraw = IRACanWithdraw(sim)
+	a.canDeposit = IRACanDeposit(sim)
+	a.limit = IRAContributionLimit(sim)
+	a.limitName = "ira"
+	var exempt PenaltyExemption
+	if seppAge > 0.0 {
+		a.sepp = SEPPRMD(sim, seppAge)
+		exempt = SEPPExemption(a.sepp)
+	}
+	a.withdrawTax = EarlyWithdrawalTax(sim, OrdinaryIncomeTax(sim), exempt)
 	a.Transaction(balance, a.StartDate(), OpenAccount)
 	return a
 }
 
-func NewInheritedIRA(sim *Simulation, balance float64, inheritDate time.Time, name string) *InvestmentAccount {
+func NewInheritedIRA(sim *Simulation, balance float64, inheritDate, ownerBirthDate time.Time, eligible bool, name string) *InvestmentAccount {
 	a := &InvestmentAccount{
 		Simulacrum: NewSimulacrum(sim),
 		name: name + " IRA",
 		ledger: NewLedger(),
 		taxable: true,
 	}
-	a.rmd = InheritedIRARMD(sim, inheritDate)
+	a.rmd = InheritedIRARMD(sim, inheritDate, ownerBirthDate, eligible)
 	a.canWithdraw = InheritedIRACanWithdraw(sim)
 	a.canDeposit = InheritedIRACanDeposit(sim)
+	a.withdrawTax = OrdinaryIncomeTax(sim)
 	a.Transaction(balance, a.StartDate(), OpenAccount)
 	return a
 }
@@ -86,6 +142,22 @@ func (a *InvestmentAccount) Balance() float64 {
 	return a.balance
 }
 
+// Basis is the cost basis of the account: the money put in that has
+// already been taxed.
+func (a *InvestmentAccount) Basis() float64 {
+	return a.basis
+}
+
+// basisShare is the part of a withdrawal that comes out of the cost
+// basis when basis is drawn down in proportion to the balance.
+func (a *InvestmentAccount) basisShare(amount float64) float64 {
+	bal := a.Balance()
+	if bal <= 0.0 || a.basis <= 0.0 {
+		return 0.0
+	}
+	return a.basis * math.Min(1.0, amount / bal)
+}
+
 func (a *InvestmentAccount) BalanceOn(date time.Time) float64 {
 	return a.ledger.FilterBefore(date).Balance()
 }
@@ -106,11 +178,9 @@ func (a *InvestmentAccount) Withdraw(amount float64, date time.Time, memo string
 	if memo == "" {
 		memo = InvestmentWithdrawl
 	}
+	a.basis -= a.withdrawTax(amount, date, a)
 	t := a.Transaction(-1.0 * amount, date, memo)
 	a.CashAccount().Deposit(amount, date, memo)
-	if a.Taxable() {
-		a.TaxMen().Withhold(amount, date, false)
-	}
 	return t, nil
 }
 
@@ -121,17 +191,72 @@ func (a *InvestmentAccount) Deposit(amount float64, date time.Time, memo string)
 	if amount < 0 {
 		return nil, errors.New("Cannot deposit a negative amount")
 	}
+	if a.payroll {
+		return nil, errors.New("Contributions only come from payroll")
+	}
 	if !a.CanDeposit(date) {
 		return nil, errors.New("IRS rules prevent deposit")
 	}
+	if a.limit != nil {
+		room := a.ContributionRoom(date)
+		if room <= 0.0 {
+			return nil, errors.New("Annual contribution limit reached")
+		}
+		amount = math.Min(amount, room)
+	}
 	if memo == "" {
 		memo = InvestmentDeposit
 	}
 	a.CashAccount().Withdraw(amount, date, memo)
 	t := a.Transaction(amount, date, memo)
+	if a.deductible {
+		a.TaxMen().Deduct(amount)
+	} else {
+		a.basis += amount
+	}
+	return t, nil
+}
+
+// Contribute deposits an elective deferral straight from payroll, up to
+// the annual limit.  The money never passes through cash, and pre-tax
+// deferrals were already left out of the withholding on the paycheck.
+func (a *InvestmentAccount) Contribute(amount float64, date time.Time) (*Transaction, error) {
+	if amount == 0.0 {
+		return nil, nil
+	}
+	if amount < 0 {
+		return nil, errors.New("Cannot deposit a negative amount")
+	}
+	if !a.CanDeposit(date) {
+		return nil, errors.New("IRS rules prevent deposit")
+	}
+	if a.limit != nil {
+		room := a.ContributionRoom(date)
+		if room <= 0.0 {
+			return nil, errors.New("Annual contribution limit reached")
+		}
+		amount = math.Min(amount, room)
+	}
+	t := a.Transaction(amount, date, ElectiveDeferral)
+	if !a.taxable {
+		a.basis += amount
+	}
 	return t, nil
 }
 
+// Match deposits an employer's matching contribution, which doesn't
+// count against the employee's deferral limit.  A match into a Roth
+// account has been taxed, so it adds to the basis.
+func (a *InvestmentAccount) Match(amount float64, date time.Time) *Transaction {
+	if amount <= 0.0 {
+		return nil
+	}
+	if !a.taxable {
+		a.basis += amount
+	}
+	return a.Transaction(amount, date, EmployerMatch)
+}
+
 func (a *InvestmentAccount) CanWithdraw(date time.Time) bool {
 	return a.canWithdraw(date)
 }
@@ -149,6 +274,42 @@ func (a *InvestmentAccount) YTDWithdrawls(date time.Time) float64 {
 	return ytd
 }
 
+func (a *InvestmentAccount) YTDDeposits(date time.Time) float64 {
+	ts := a.ledger.FilterAfter(startOfYear(date)).FilterAmountMin(0.0).FilterMemoOut(MarketReturn, InterestAccrual, OpenAccount, EmployerMatch)
+	var ytd float64 = 0.0
+	for _, t := range *ts {
+		ytd += t.Amount
+	}
+	return ytd
+}
+
+// ContributionRoom returns how much more can go into the account this
+// year.  The limit is the owner's, so what they've put into any of
+// their accounts that share it counts.
+func (a *InvestmentAccount) ContributionRoom(date time.Time) float64 {
+	if a.limit == nil {
+		return math.MaxFloat64
+	}
+	room := a.limit(date) - a.YTDDeposits(date)
+	for _, acct := range a.Sim().Investments {
+		if acct != a && acct.Sim() == a.Sim() && acct.limitName == a.limitName {
+			room -= acct.YTDDeposits(date)
+		}
+	}
+	return room
+}
+
+// Flows returns what was put into the account less what was taken out,
+// from one date up to another, leaving out its returns.
+func (a *InvestmentAccount) Flows(from, to time.Time) float64 {
+	ts := a.ledger.FilterAfter(from).FilterBefore(to).FilterMemoOut(MarketReturn, InterestAccrual, OpenAccount)
+	var flows float64 = 0.0
+	for _, t := range *ts {
+		flows += t.Amount
+	}
+	return flows
+}
+
 func (a *InvestmentAccount) RMD(date time.Time) float64 {
 	annual := a.rmd(date, a)
 	rmd := annual - a.YTDWithdrawls(date)
@@ -162,6 +323,36 @@ func (a *InvestmentAccount) TakeRMD(date time.Time) (*Transaction, error) {
 	return a.Withdraw(a.RMD(date), date, RequiredMinimumDistribution)
 }
 
+// SEPP returns what's left of the year's 72(t) payment.
+func (a *InvestmentAccount) SEPP(date time.Time) float64 {
+	if a.sepp == nil {
+		return 0.0
+	}
+	due := a.sepp(date, a) - a.YTDWithdrawls(date)
+	if due < 0.0 {
+		return 0.0
+	}
+	return due
+}
+
+func (a *InvestmentAccount) TakeSEPP(date time.Time) (*Transaction, error) {
+	return a.Withdraw(a.SEPP(date), date, PeriodicPayment)
+}
+
+// PayDividends records the year's qualified dividends.  Dividends are
+// already part of the market return and are reinvested, so they only
+// add to the cost basis, but they're taxed as they're paid.
+func (a *InvestmentAccount) PayDividends(date time.Time) float64 {
+	bal := a.Balance()
+	if a.dividendYield <= 0.0 || bal <= 0.0 {
+		return 0.0
+	}
+	div := bal * a.dividendYield
+	a.basis += div
+	a.TaxMen().CapitalGain(div)
+	return div
+}
+
 func (a *InvestmentAccount) AccrueInterest(date time.Time) *Transaction {
 	return nil
 }
@@ -171,16 +362,50 @@ func (a *InvestmentAccount) AccrueMarketReturn(date time.Time) *Transaction {
 	if bal <= 0.0 {
 		return nil
 	}
-	ret := a.Sim().Portfolio.PortfolioReturn(date)
+	portfolio := a.Portfolio()
+	ret := portfolio.AccountReturn(a, date)
 	if ret < -1.0 {
 		ret = -1.0
 	}
 	amt := bal * ret
-	return a.Transaction(amt, date, MarketReturn)
+	t := a.Transaction(amt, date, MarketReturn)
+	portfolio.Rebalance(a, date)
+	return t
 }
 
+// Portfolio returns the portfolio the account is invested in: its own,
+// or else the household's.
+func (a *InvestmentAccount) Portfolio() *Portfolio {
+	if a.portfolio != nil {
+		return a.portfolio
+	}
+	return a.Sim().Portfolio
+}
+
+// Deductible reports whether deposits are deducted from taxable
+// income.
+func (a *InvestmentAccount) Deductible() bool {
+	return a.deductible
+}
+
+// TaxFree reports whether earnings can come out without being taxed,
+// as they can from Roth accounts.
+func (a *InvestmentAccount) TaxFree() bool {
+	return a.taxFree
+}
+
+// Taxable reports whether withdrawals are taxed as ordinary income.
+// Brokerage accounts aren't, though their gains are still taxed.
 func (a *InvestmentAccount) Taxable() bool {
 	return a.taxable
 }
 
-
+// CapitalGainsTax taxes the gain on a withdrawal from a brokerage
+// account, using the average cost of everything in the account.
+func CapitalGainsTax(sim *Simulation) WithdrawTax {
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		basis := acct.basisShare(amount)
+		sim.TaxMen.CapitalGain(amount - basis)
+		return basis
+	}
+}
@@ -1,6 +1,7 @@
 package sim
 
 import (
+	"math"
 	"time"
 )
 
@@ -19,23 +20,108 @@ var iraLifeExpectancies = []float64{
 	 1.1,  1.0,
 }
 
+// singleLifeExpectancies is the Single Life Table in effect from 2022,
+// by age.  iraLifeExpectancies is the table it replaced.
+var singleLifeExpectancies = []float64{
+	84.6, 83.7, 82.8, 81.8, 80.8, 79.8, 78.8, 77.9, 76.9, 75.9,
+	74.9, 73.9, 72.9, 71.9, 70.9, 69.9, 69.0, 68.0, 67.0, 66.0,
+	65.0, 64.1, 63.1, 62.1, 61.1, 60.2, 59.2, 58.2, 57.3, 56.3,
+	55.3, 54.4, 53.4, 52.5, 51.5, 50.5, 49.6, 48.6, 47.7, 46.7,
+	45.7, 44.8, 43.8, 42.9, 41.9, 41.0, 40.0, 39.0, 38.1, 37.1,
+	36.2, 35.3, 34.3, 33.4, 32.5, 31.6, 30.6, 29.8, 28.9, 28.0,
+	27.1, 26.2, 25.4, 24.5, 23.7, 22.9, 22.0, 21.2, 20.4, 19.6,
+	18.8, 18.0, 17.2, 16.4, 15.6, 14.8, 14.1, 13.3, 12.6, 11.9,
+	11.2, 10.5,  9.9,  9.3,  8.7,  8.1,  7.6,  7.1,  6.6,  6.1,
+	 5.7,  5.3,  4.9,  4.6,  4.3,  4.0,  3.7,  3.4,  3.2,  3.0,
+	 2.8,  2.6,  2.5,  2.3,  2.2,  2.1,  2.1,  2.1,  2.0,  2.0,
+	 2.0,  2.0,  2.0,  1.9,  1.9,  1.8,  1.8,  1.6,  1.4,  1.1,
+	 1.0,
+}
+
+// EarlyWithdrawalAge is the age from which retirement account
+// withdrawals are no longer penalized.
+const EarlyWithdrawalAge = 59.5
+
 type RMD func(date time.Time, acct Account) float64
 type WithdrawRule func(date time.Time) bool
 type DepositRule func(date time.Time) bool
 
-func InheritedIRARMD(sim *Simulation, inheritanceDate time.Time) RMD {
+// ContributionLimit returns the most that can be put into an account
+// in the year containing date.
+type ContributionLimit func(date time.Time) float64
+
+// PenaltyExemption returns how much of a withdrawal is exempt from the
+// early withdrawal penalty.
+type PenaltyExemption func(amount float64, date time.Time, acct *InvestmentAccount) float64
+
+// SEPPSchedule returns the annual payment due under a 72(t) schedule of
+// substantially equal periodic payments, or 0 outside the schedule.
+type SEPPSchedule func(date time.Time, acct *InvestmentAccount) float64
+
+// WithdrawTax settles the taxes on a withdrawal before it's taken out
+// of the account, and returns how much of the account's cost basis the
+// withdrawal used up.
+type WithdrawTax func(amount float64, date time.Time, acct *InvestmentAccount) float64
+
+// OrdinaryIncomeTax taxes the whole withdrawal as ordinary income.
+func OrdinaryIncomeTax(sim *Simulation) WithdrawTax {
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		sim.TaxMen.Withhold(amount, date, false)
+		return acct.basisShare(amount)
+	}
+}
+
+// EarlyWithdrawalTax adds the early withdrawal penalty to tax.  Before
+// 59½ the penalty applies to whatever tax doesn't treat as basis,
+// less anything exempt.
+func EarlyWithdrawalTax(sim *Simulation, tax WithdrawTax, exempt PenaltyExemption) WithdrawTax {
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		var exempted float64 = 0.0
+		if exempt != nil {
+			exempted = exempt(amount, date, acct)
+		}
+		basis := tax(amount, date, acct)
+		if sim.Actuary.Age(date) < EarlyWithdrawalAge {
+			sim.TaxMen.EarlyWithdrawal(amount - basis - exempted, date)
+		}
+		return basis
+	}
+}
+
+// InheritedIRARMD returns the distributions required of a beneficiary.
+// Heirs of owners who died before 2020, and eligible designated
+// beneficiaries (including anyone less than ten years younger than the
+// owner), stretch distributions over their own single life expectancy.
+// Everyone else must empty the account by the end of the tenth year
+// after the owner's death, taking annual distributions along the way
+// only if the owner had reached their required beginning date.  An
+// unknown owner birth date is treated as a death before that date.
+func InheritedIRARMD(sim *Simulation, inheritanceDate, ownerBirthDate time.Time, eligible bool) RMD {
 	basisDate := endOfYear(endOfYear(inheritanceDate).Add(24 * time.Hour))
 	basisAge := int(sim.Actuary.Age(basisDate))
 	if basisAge < 0 {
 		basisAge = 0
-	} else if basisAge >= len(iraLifeExpectancies) {
-		basisAge = len(iraLifeExpectancies) - 1
 	}
+	knownOwner := !ownerBirthDate.IsZero()
+	if knownOwner && sim.Actuary.BirthDate.Before(ownerBirthDate.AddDate(10, 0, 0)) {
+		eligible = true
+	}
+	tenYear := !eligible && inheritanceDate.Year() >= 2020
+	annual := !tenYear || (knownOwner && !inheritanceDate.Before(RequiredBeginningDate(ownerBirthDate)))
+	deadline := inheritanceDate.Year() + 10
 	return func(date time.Time, acct Account) float64 {
-		elapsed := float64(date.Year() - basisDate.Year())
-		basis := iraLifeExpectancies[basisAge] - elapsed
 		yeBal := acct.YearEndBalance(date)
 		bal := acct.Balance()
+		if tenYear && date.Year() >= deadline {
+			return bal
+		}
+		// annual distributions under the ten year rule were waived
+		// through 2024
+		if !annual || (tenYear && date.Year() < 2025) || date.Year() < basisDate.Year() {
+			return 0.0
+		}
+		elapsed := float64(date.Year() - basisDate.Year())
+		basis := SingleLifeExpectancy(basisAge, date.Year()) - elapsed
 		if basis < 1.0 {
 			if yeBal > bal {
 				return bal
@@ -69,17 +155,77 @@ var k401LifeExpectancies = []float64{
 	 4.2,  3.9,  3.7,  3.4,  3.1,  2.9,  2.6,  2.4,  2.1,  1.9,
 }
 
+// uniformLifetimeExpectancies is the Uniform Lifetime Table in effect
+// from 2022, from age 72.  k401LifeExpectancies is the table it
+// replaced, which starts at 70.
+var uniformLifetimeExpectancies = []float64{
+	27.4, 26.5, 25.5, 24.6, 23.7, 22.9, 22.0, 21.1, 20.2, 19.4, 18.5, 17.7,
+	16.8, 16.0, 15.2, 14.4, 13.7, 12.9, 12.2, 11.5, 10.8, 10.1,  9.5,  8.9,
+	 8.4,  7.8,  7.3,  6.8,  6.4,  6.0,  5.6,  5.2,  4.9,  4.6,  4.3,  4.1,
+	 3.9,  3.7,  3.5,  3.4,  3.3,  3.1,  3.0,  2.9,  2.8,  2.7,  2.5,  2.3,
+	 2.0,
+}
+
+func lookupLifeExpectancy(table []float64, n int) float64 {
+	if n < 0 {
+		n = 0
+	} else if n >= len(table) {
+		n = len(table) - 1
+	}
+	return table[n]
+}
+
+// SingleLifeExpectancy returns the single life divisor for an age,
+// from the table in effect in the given year.
+func SingleLifeExpectancy(age, year int) float64 {
+	if year >= 2022 {
+		return lookupLifeExpectancy(singleLifeExpectancies, age)
+	}
+	return lookupLifeExpectancy(iraLifeExpectancies, age)
+}
+
+// UniformLifetime returns the uniform lifetime divisor for an age, from
+// the table in effect in the given year.
+func UniformLifetime(age, year int) float64 {
+	if year >= 2022 {
+		return lookupLifeExpectancy(uniformLifetimeExpectancies, age - 72)
+	}
+	return lookupLifeExpectancy(k401LifeExpectancies, age - 70)
+}
+
+// RMDStartAge returns the age required distributions start at, which
+// the SECURE Acts tie to the owner's year of birth.
+func RMDStartAge(birthDate time.Time) float64 {
+	if birthDate.Before(time.Date(1949, time.July, 1, 0, 0, 0, 0, birthDate.Location())) {
+		return 70.5
+	}
+	if birthDate.Year() < 1951 {
+		return 72.0
+	}
+	if birthDate.Year() < 1960 {
+		return 73.0
+	}
+	return 75.0
+}
+
+// RequiredBeginningDate is April 1 of the year after the owner reaches
+// the RMD start age.
+func RequiredBeginningDate(birthDate time.Time) time.Time {
+	yf := RMDStartAge(birthDate)
+	yi := int(yf)
+	d := int(365.25 * (yf - float64(yi)))
+	reached := birthDate.AddDate(yi, 0, d)
+	return time.Date(reached.Year() + 1, time.April, 1, 0, 0, 0, 0, birthDate.Location())
+}
+
 func K401RMD(sim *Simulation) RMD {
+	startAge := RMDStartAge(sim.Actuary.BirthDate)
 	return func(date time.Time, acct Account) float64 {
 		age := sim.Actuary.Age(endOfYear(date))
-		if age < 70.5 {
+		if age < startAge {
 			return 0.0
 		}
-		n := int(age) - 70
-		if n >= len(k401LifeExpectancies) {
-			n = len(k401LifeExpectancies) - 1
-		}
-		denom := k401LifeExpectancies[n]
+		denom := UniformLifetime(int(age), date.Year())
 		yeBal := acct.YearEndBalance(date)
 		bal := acct.Balance()
 		rmd := yeBal / denom
@@ -90,9 +236,24 @@ func K401RMD(sim *Simulation) RMD {
 	}
 }
 
+// K401CanWithdraw always allows withdrawals.  Early withdrawals are
+// penalized by EarlyWithdrawalTax instead.
 func K401CanWithdraw(sim *Simulation) WithdrawRule {
 	return func(date time.Time) bool {
-		return sim.Actuary.Age(date) >= 59.5
+		return true
+	}
+}
+
+// RuleOf55 exempts withdrawals made after separating from service in or
+// after the year the owner turns 55.
+func RuleOf55(sim *Simulation) PenaltyExemption {
+	retire := sim.RetirementDate()
+	eligible := sim.Actuary.Age(endOfYear(retire)) >= 55.0
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		if eligible && !date.Before(retire) {
+			return amount
+		}
+		return 0.0
 	}
 }
 
@@ -102,3 +263,75 @@ func K401CanDeposit(sim *Simulation) DepositRule {
 	}
 }
 
+// IRACanWithdraw always allows withdrawals.  Early withdrawals are
+// penalized by EarlyWithdrawalTax instead.
+func IRACanWithdraw(sim *Simulation) WithdrawRule {
+	return func(date time.Time) bool {
+		return true
+	}
+}
+
+// SEPPRMD is a 72(t) schedule using the required minimum distribution
+// method: each year's payment is the previous year-end balance divided
+// by the owner's single life expectancy.  Payments start in the year
+// the owner reaches startAge and run for five years or until 59½,
+// whichever is longer.
+func SEPPRMD(sim *Simulation, startAge float64) SEPPSchedule {
+	birthDate := sim.Actuary.BirthDate
+	start := birthDate.AddDate(int(startAge), 0, int(365.25 * (startAge - float64(int(startAge)))))
+	end := start.AddDate(5, 0, 0)
+	if unpenalized := birthDate.AddDate(59, 6, 0); unpenalized.After(end) {
+		end = unpenalized
+	}
+	return func(date time.Time, acct *InvestmentAccount) float64 {
+		if date.Year() < start.Year() || !date.Before(end) {
+			return 0.0
+		}
+		age := int(sim.Actuary.Age(endOfYear(date)))
+		return acct.YearEndBalance(date) / SingleLifeExpectancy(age, date.Year())
+	}
+}
+
+// SEPPExemption exempts withdrawals up to the year's scheduled payment.
+// Anything beyond that is penalized, though the retroactive penalty
+// for breaking a schedule isn't modeled.
+func SEPPExemption(schedule SEPPSchedule) PenaltyExemption {
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		due := schedule(date, acct) - acct.YTDWithdrawls(date)
+		return math.Max(0.0, math.Min(amount, due))
+	}
+}
+
+// IRACanDeposit requires earned income, so contributions stop at
+// retirement.
+func IRACanDeposit(sim *Simulation) DepositRule {
+	return func(date time.Time) bool {
+		return sim.Actuary.Age(date) < sim.RetirementAge()
+	}
+}
+
+// IRAContributionLimit is the annual IRA limit, plus the catch-up
+// contribution from the year the owner turns 50.  The owner's
+// traditional and Roth IRAs share it.
+func IRAContributionLimit(sim *Simulation) ContributionLimit {
+	return func(date time.Time) float64 {
+		limit := sim.TaxMen.Federal.Limit("ira", date.Year())
+		if sim.Actuary.Age(endOfYear(date)) >= 50.0 {
+			limit += sim.TaxMen.Federal.Limit("ira_catch_up", date.Year())
+		}
+		return limit
+	}
+}
+
+// K401ContributionLimit is the annual elective deferral limit, plus
+// the catch-up contribution from the year the owner turns 50.  The
+// owner's traditional and Roth 401k deferrals share it.
+func K401ContributionLimit(sim *Simulation) ContributionLimit {
+	return func(date time.Time) float64 {
+		limit := sim.TaxMen.Federal.Limit("401k", date.Year())
+		if sim.Actuary.Age(endOfYear(date)) >= 50.0 {
+			limit += sim.TaxMen.Federal.Limit("401k_catch_up", date.Year())
+		}
+		return limit
+	}
+}
@@ -0,0 +1,108 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUniformLifetime(t *testing.T) {
+	tests := []struct {
+		age int
+		year int
+		want float64
+	}{
+		{70, 2021, 27.4},
+		{75, 2021, 22.9},
+		{72, 2022, 27.4},
+		{75, 2024, 24.6},
+		{80, 2024, 20.2},
+		{130, 2024, 2.0},
+	}
+	for _, tt := range tests {
+		got := UniformLifetime(tt.age, tt.year)
+		if got != tt.want {
+			t.Errorf("UniformLifetime(%d, %d) = %v, want %v", tt.age, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestRMDStartAge(t *testing.T) {
+	tests := []struct {
+		birthDate time.Time
+		want float64
+		rbd time.Time
+	}{
+		{testDate(1949, time.June, 30), 70.5, testDate(2020, time.April, 1)},
+		{testDate(1949, time.July, 1), 72.0, testDate(2022, time.April, 1)},
+		{testDate(1950, time.December, 31), 72.0, testDate(2023, time.April, 1)},
+		{testDate(1951, time.January, 1), 73.0, testDate(2025, time.April, 1)},
+		{testDate(1959, time.December, 31), 73.0, testDate(2033, time.April, 1)},
+		{testDate(1960, time.January, 1), 75.0, testDate(2036, time.April, 1)},
+	}
+	for _, tt := range tests {
+		got := RMDStartAge(tt.birthDate)
+		if got != tt.want {
+			t.Errorf("RMDStartAge(%s) = %v, want %v", tt.birthDate.Format("2006-01-02"), got, tt.want)
+		}
+		rbd := RequiredBeginningDate(tt.birthDate)
+		if !rbd.Equal(tt.rbd) {
+			t.Errorf("RequiredBeginningDate(%s) = %s, want %s", tt.birthDate.Format("2006-01-02"),
+				rbd.Format("2006-01-02"), tt.rbd.Format("2006-01-02"))
+		}
+	}
+}
+
+// testOwner is the sample household with an owner born mid-year, so
+// their age each December is clear of the birthday.
+func testOwner(t *testing.T) *Simulation {
+	config := testConfig(t)
+	config.BirthDate = "1980-06-15"
+	return testSimulation(t, config)
+}
+
+// TestK401RMD follows an account the owner never touches: nothing is
+// due until the year they turn 75.
+func TestK401RMD(t *testing.T) {
+	s := testOwner(t)
+	acct := New401K(s, 100000, "Test")
+	rmd := K401RMD(s)
+	tests := []struct {
+		date time.Time
+		want float64
+	}{
+		{testDate(2054, time.June, 1), 0},
+		{testDate(2055, time.June, 1), 100000 / 24.6},
+		{testDate(2056, time.June, 1), 100000 / 23.7},
+	}
+	for _, tt := range tests {
+		got := rmd(tt.date, acct)
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("RMD in %d = %v, want %v", tt.date.Year(), got, tt.want)
+		}
+	}
+}
+
+// TestSEPPRMD follows a schedule started at 50, which has to run until
+// 59½ since that's longer than five years.
+func TestSEPPRMD(t *testing.T) {
+	s := testOwner(t)
+	acct := New401K(s, 100000, "Test")
+	sepp := SEPPRMD(s, 50.0)
+	tests := []struct {
+		date time.Time
+		want float64
+	}{
+		{testDate(2029, time.December, 1), 0},
+		{testDate(2030, time.June, 1), 100000 / 36.2},
+		{testDate(2031, time.June, 1), 100000 / 35.3},
+		{testDate(2039, time.December, 1), 100000 / singleLifeExpectancies[59]},
+		{testDate(2039, time.December, 15), 0},
+	}
+	for _, tt := range tests {
+		got := sepp(tt.date, acct)
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("SEPP on %s = %v, want %v", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -5,11 +5,45 @@ import (
 	"time"
 )
 
+// MatchTier matches Rate of every dollar deferred, up to UpTo of pay.
+type MatchTier struct {
+	Rate float64
+	UpTo float64
+}
+
+// K401Plan is an employer's 401k plan.  Deferral is the share of each
+// paycheck that goes into Account, and the employer matches deferrals
+// tier by tier.  Deferrals into a Roth 401k are still taxable wages.
+type K401Plan struct {
+	Account *InvestmentAccount
+	Deferral float64
+	Match []*MatchTier
+}
+
+// EmployerMatch returns the employer's contribution on a paycheck, given
+// how much of it was actually deferred.
+func (p *K401Plan) EmployerMatch(pay, deferred float64) float64 {
+	if pay <= 0.0 {
+		return 0.0
+	}
+	pct := deferred / pay
+	var match float64 = 0.0
+	var prev float64 = 0.0
+	for _, tier := range p.Match {
+		if pct > prev {
+			match += tier.Rate * (math.Min(pct, tier.UpTo) - prev)
+		}
+		prev = tier.UpTo
+	}
+	return match * pay
+}
+
 type Job struct {
 	*Simulacrum
 	employed int
 	unemployed int
 	monthly float64
+	Plan *K401Plan
 }
 
 func NewJob(sim *Simulation, baseAnnualSalary float64) *Job {
@@ -122,10 +156,39 @@ func (j *Job) Unemployment(date time.Time) float64 {
 	return 0.0
 }
 
+// PreTax returns how much of a deferral comes out of taxable wages.
+func (p *K401Plan) PreTax(deferred float64) float64 {
+	if p == nil || p.Account.TaxFree() {
+		return 0.0
+	}
+	return deferred
+}
+
+// Defer moves the plan's deferral out of a paycheck and into the 401k,
+// along with the employer match, and returns the amount deferred.
+// Matches into a Roth 401k are taxed as wages when they're made.
+func (j *Job) Defer(pay float64, date time.Time) float64 {
+	if j.Plan == nil || pay <= 0.0 {
+		return 0.0
+	}
+	var deferred float64 = 0.0
+	t, _ := j.Plan.Account.Contribute(pay * j.Plan.Deferral, date)
+	if t != nil {
+		deferred = t.Amount
+	}
+	m := j.Plan.Account.Match(j.Plan.EmployerMatch(pay, deferred), date)
+	if m != nil && j.Plan.Account.TaxFree() {
+		j.TaxMen().Withhold(m.Amount, date, false)
+	}
+	return deferred
+}
+
 func (j *Job) Monthly(date time.Time) {
 	amount := j.Earn(date)
-	j.CashAccount().Deposit(amount, date, "Salary")
-	j.TaxMen().Withhold(amount, date, true)
+	deferred := j.Defer(amount, date)
+	j.CashAccount().Deposit(amount - deferred, date, "Salary")
+	j.TaxMen().WithholdWages(amount, j.Plan.PreTax(deferred), date)
+	j.Sim().SocialSecurity.Credit(amount, date)
 	unem := j.Unemployment(date)
 	j.CashAccount().Deposit(unem, date, "Unemployment")
 	j.TaxMen().Withhold(unem, date, false)
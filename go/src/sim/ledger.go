@@ -21,6 +21,10 @@ const (
 	RequiredMinimumDistribution = "Required Minimum Distribution"
 	InvestmentDeposit = "Investment Deposit"
 	InvestmentWithdrawl = "Investment Withdrawl"
+	EarlyWithdrawalPenalty = "Early Withdrawal Penalty"
+	PeriodicPayment = "Substantially Equal Periodic Payment"
+	ElectiveDeferral = "Elective Deferral"
+	EmployerMatch = "Employer Match"
 )
 
 type Transaction struct {
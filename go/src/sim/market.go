@@ -16,10 +16,12 @@ type nextRegimeGetter func(r *Regime) *Regime
 
 type Regime struct {
 	*Simulacrum
+	name string
 	durationArgs *gaussianInputs
 	meanReturnArgs *gaussianInputs
 	volatilityArgs *gaussianInputs
 	nexter nextRegimeGetter
+	state *RegimeState
 	duration int
 	meanReturn float64
 	vol float64
@@ -27,9 +29,10 @@ type Regime struct {
 	next *Regime
 }
 
-func newRegime(sim *Simulation, durArgs, mretArgs, volArgs *gaussianInputs, nexter nextRegimeGetter) *Regime {
+func newRegime(sim *Simulation, name string, durArgs, mretArgs, volArgs *gaussianInputs, nexter nextRegimeGetter) *Regime {
 	r := &Regime{
 		Simulacrum: NewSimulacrum(sim),
+		name: name,
 		durationArgs: durArgs,
 		meanReturnArgs: mretArgs,
 		volatilityArgs: volArgs,
@@ -56,6 +59,10 @@ func newRegime(sim *Simulation, durArgs, mretArgs, volArgs *gaussianInputs, next
 	return r
 }
 
+func (r *Regime) Name() string {
+	return r.name
+}
+
 func (r *Regime) Duration() int {
 	return r.duration
 }
@@ -90,101 +97,34 @@ func (r *Regime) Next() *Regime {
 	return r.next
 }
 
-func NewRecovery(sim *Simulation) *Regime {
-	dur := &gaussianInputs{mean: 12.0, stddev: 6.0, min: 6.0, max: 18.0}
-	mret := &gaussianInputs{mean: 12.0, stddev: 3.0, min: 5.0, max: math.MaxFloat64}
-	vol := &gaussianInputs{mean: 3.0, stddev: 0.0, min: 3.0, max: 3.0}
-	nexter := func(r *Regime) *Regime {
-		return NewExpansion(r.Sim())
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
-}
-
-func NewExpansion(sim *Simulation) *Regime {
-	dur := &gaussianInputs{mean: 72.0, stddev: 24.0, min: 48.0, max: 96.0}
-	mret := &gaussianInputs{mean: 10.0, stddev: 0.0, min: 10.0, max: 10.0}
-	vol := &gaussianInputs{mean: 2.0, stddev: 0.0, min: 2.0, max: 2.0}
-	nexter := func(r *Regime) *Regime {
-		if r.Duration() >= 30 {
-			if r.RandTan(0.3, 10.0, 0.0, -1.0) > 0.5 {
-				return NewBubble(r.Sim())
-			}
-		}
-		return NewRecession(r.Sim())
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
-}
-
-func NewBubble(sim *Simulation) *Regime {
-	dur := &gaussianInputs{mean: 12.0, stddev: 6.0, min: 2.0, max: math.MaxFloat64}
-	mret := &gaussianInputs{mean: 15.0, stddev: 0.0, min: 15.0, max: 15.0}
-	vol := &gaussianInputs{mean: 0.5, stddev: 0.0, min: 0.5, max: 0.5}
-	nexter := func(r *Regime) *Regime {
-		if r.RandTan(0.5, 10.0, 0.0, -1.0) > 0.75 {
-			return NewDepression(r.Sim())
-		}
-		return NewRecession(r.Sim())
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
-}
-
-func NewRecession(sim *Simulation) *Regime {
-	dur := &gaussianInputs{mean: 12.0, stddev: 6.0, min: 6.0, max: 24.0}
-	mret := &gaussianInputs{mean: -5.0, stddev: 0.0, min: -5.0, max: -5.0}
-	vol := &gaussianInputs{mean: 2.0, stddev: 0.0, min: 2.0, max: 2.0}
-	nexter := func(r *Regime) *Regime {
-		if r.Random() > 0.8 {
-			return NewStagnation(r.Sim(), -1)
-		}
-		return NewRecovery(r.Sim())
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
-}
-
-func NewDepression(sim *Simulation) *Regime {
-	dur := &gaussianInputs{mean: 12.0, stddev: 3.0, min: 6.0, max: 24.0}
-	mret := &gaussianInputs{mean: -30.0, stddev: 5.0, min: -40.0, max: -20.0}
-	vol := &gaussianInputs{mean: 5.0, stddev: 0.0, min: 5.0, max: 5.0}
-	nexter := func(r *Regime) *Regime {
-		sdur := int(r.ClampedGauss(48.0, 12.0, 36.0, 60.0))
-		return NewStagnation(r.Sim(), sdur)
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
+// MarketModel generates a century of monthly history for the economy:
+// stock market, bond and T-bill returns and inflation, all in annualized
+// percent.
+type MarketModel interface {
+	MarketReturns() []float64
+	BondReturns() []float64
+	BillReturns() []float64
+	InflationRates() []float64
 }
 
-func NewStagnation(sim *Simulation, duration int) *Regime {
-	var dur *gaussianInputs
-	if duration <= 0 {
-		dur = &gaussianInputs{mean: 24.0, stddev: 6.0, min: 12.0, max: 36.0}
-	} else {
-		fdur := float64(duration)
-		dur = &gaussianInputs{mean: fdur, stddev: 0.0, min: fdur, max: fdur}
-	}
-	mret := &gaussianInputs{mean: 2.0, stddev: 0.0, min: 2.0, max: 2.0}
-	vol := &gaussianInputs{mean: 1.0, stddev: 0.0, min: 1.0, max: 1.0}
-	nexter := func(r *Regime) *Regime {
-		return NewRecovery(r.Sim())
-	}
-	return newRegime(sim, dur, mret, vol, nexter)
-}
-
-type Economy struct {
+// RegimeModel is the synthetic market: a chain of regimes walked through
+// a regime graph, with inflation following regimes of its own.
+type RegimeModel struct {
 	*Simulacrum
 	root *Regime
+	correlations *CorrelationConfig
 	returns []float64
+	regimes []*Regime
+	inflation []float64
+	bonds []float64
+	bills []float64
 }
 
-func NewEconomy(sim *Simulation) *Economy {
-	return &Economy{
-		Simulacrum: NewSimulacrum(sim),
-		root: NewRecession(sim),
-	}
-}
-
-func (e *Economy) MarketReturns() []float64 {
-	if e.returns == nil {
+func (m *RegimeModel) MarketReturns() []float64 {
+	if m.returns == nil {
 		rets := make([]float64, 1200)
-		r := e.root
+		m.regimes = make([]*Regime, 1200)
+		r := m.root
 		i := 0
 		for i < 1200 {
 			rrets := r.MarketReturns()
@@ -193,58 +133,128 @@ func (e *Economy) MarketReturns() []float64 {
 					break
 				}
 				rets[i+j] = rrets[j]
+				m.regimes[i+j] = r
 			}
 			i += len(rrets)
 			r = r.Next()
 		}
-		e.returns = rets
+		m.returns = rets
+	}
+	return m.returns
+}
+
+type Economy struct {
+	*Simulacrum
+	model MarketModel
+	prices []float64
+}
+
+const (
+	EconomyRegime = "regime"
+	EconomyHistorical = "historical"
+	EconomyBootstrap = "bootstrap"
+)
+
+// EconomyModels lists the models economy.model can name.
+var EconomyModels = []string{EconomyRegime, EconomyHistorical, EconomyBootstrap}
+
+// NewEconomy returns the economy for the nth simulation.
+func NewEconomy(sim *Simulation, n int, config *EconomyConfig) *Economy {
+	e := &Economy{
+		Simulacrum: NewSimulacrum(sim),
+	}
+	if config != nil && config.Model == EconomyHistorical {
+		e.model = NewHistoricalModel(History, n, config.StartYear)
+		return e
+	}
+	if config != nil && config.Model == EconomyBootstrap {
+		e.model = NewBootstrapModel(e.Simulacrum, History, config.BlockLength)
+		return e
+	}
+	graph := DefaultRegimes
+	if config != nil && config.Regimes != nil {
+		graph = NewRegimeGraph(config.Regimes)
 	}
-	return e.returns
+	// the regime model shares the economy's random numbers, which
+	// nothing else uses
+	m := &RegimeModel{
+		Simulacrum: e.Simulacrum,
+		root: graph.NewRegime(sim, graph.Start),
+	}
+	if config != nil {
+		m.correlations = config.Correlations
+	}
+	e.model = m
+	return e
+}
+
+func (e *Economy) MarketReturns() []float64 {
+	return e.model.MarketReturns()
 }
 
 func (e *Economy) MarketReturn(date time.Time) float64 {
+	return e.monthOf(e.MarketReturns(), date)
+}
+
+// BondReturn returns the annualized return on bonds for a month, in
+// percent.
+func (e *Economy) BondReturn(date time.Time) float64 {
+	return e.monthOf(e.model.BondReturns(), date)
+}
+
+// BillReturn returns the annualized return on T-bills, which is what
+// cash earns, for a month, in percent.
+func (e *Economy) BillReturn(date time.Time) float64 {
+	return e.monthOf(e.model.BillReturns(), date)
+}
+
+func (e *Economy) monthOf(series []float64, date time.Time) float64 {
 	d := months(date.Sub(e.StartDate()))
-	if d < 0 {
+	if d < 0 || d >= len(series) {
 		return 0.0
 	}
-	mrets := e.MarketReturns()
-	if d >= len(mrets) {
-		return 0.0
-	}
-	return mrets[d]
+	return series[d]
 }
 
+// Inflation returns the annualized rate of inflation for a month, in
+// percent.
 func (e *Economy) Inflation(date time.Time) float64 {
 	d := months(date.Sub(e.StartDate()))
 	if d < 0 {
 		return 0.0
 	}
-	if d < 6 {
-		return 2.0
-	}
-	mrets := e.MarketReturns()
-	if d >= len(mrets) {
+	infl := e.model.InflationRates()
+	if d >= len(infl) {
 		return 2.0
 	}
-	var s float64 = 0.0
-	for i := d - 6; i < d; i++ {
-		s += mrets[i] / 6.0
-	}
-	if s >= 10.0 {
-		return 3.0
-	}
-	if s >= 7.5 {
-		return 2.5
-	}
-	if s >= 4.0 {
-		return 2.0
+	return infl[d]
+}
+
+// PriceIndex returns the price level on a date, relative to 1.0 at the
+// start of the simulation.  Dividing by it turns nominal dollars into
+// today's dollars.  Months before the start are assumed to have seen
+// the same inflation as the first month.
+func (e *Economy) PriceIndex(date time.Time) float64 {
+	d := months(startOfMonth(date).Sub(e.StartDate()))
+	if d < 0 {
+		return math.Pow(1.0 + e.Inflation(e.StartDate()) / 1200.0, float64(d))
 	}
-	if s >= 0.0 {
-		return 1.0
+	if e.prices == nil {
+		infl := e.model.InflationRates()
+		e.prices = make([]float64, len(infl) + 1)
+		e.prices[0] = 1.0
+		for i, rate := range infl {
+			e.prices[i+1] = e.prices[i] * (1.0 + rate / 1200.0)
+		}
 	}
-	if s >= -4.0 {
-		return 0.5
+	n := len(e.prices) - 1
+	if d > n {
+		return e.prices[n] * math.Pow(1.0 + 2.0 / 1200.0, float64(d - n))
 	}
-	return 0.0
+	return e.prices[d]
 }
 
+// Inflator returns how much prices grow between two dates.
+func (e *Economy) Inflator(from, to time.Time) float64 {
+	return e.PriceIndex(to) / e.PriceIndex(from)
+}
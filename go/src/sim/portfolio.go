@@ -1,45 +1,244 @@
 package sim
 
 import (
+	"math"
+	"sort"
 	"time"
 )
 
+// GlidePath sets the target allocation on a date.
+type GlidePath func(date time.Time) *Allocation
+
+// GlidePoint pins the allocation at one point along a glide path, which
+// moves in a straight line between points and holds steady past the
+// first and last.
+type GlidePoint struct {
+	At float64
+	Allocation *Allocation
+}
+
+func interpolateAllocation(points []*GlidePoint, at float64) *Allocation {
+	if at <= points[0].At {
+		return points[0].Allocation
+	}
+	for i := 1; i < len(points); i++ {
+		if at <= points[i].At {
+			a, b := points[i-1], points[i]
+			f := (at - a.At) / (b.At - a.At)
+			return &Allocation{
+				Stocks: a.Allocation.Stocks + f * (b.Allocation.Stocks - a.Allocation.Stocks),
+				Bonds: a.Allocation.Bonds + f * (b.Allocation.Bonds - a.Allocation.Bonds),
+				Cash: a.Allocation.Cash + f * (b.Allocation.Cash - a.Allocation.Cash),
+			}
+		}
+	}
+	return points[len(points) - 1].Allocation
+}
+
+func sortGlidePoints(points []*GlidePoint) []*GlidePoint {
+	sorted := append([]*GlidePoint{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].At < sorted[j].At
+	})
+	return sorted
+}
+
+// StagedGlidePath steps between four allocations: more than 40 years
+// from retirement, up to 5 years out, in the last 5 years, and once
+// retired.
+func StagedGlidePath(sim *Simulation, speculative, aggressive, moderate, conservative *Allocation) GlidePath {
+	return func(date time.Time) *Allocation {
+		yearsToRetirement := sim.RetirementAge() - sim.Actuary.Age(date)
+		if yearsToRetirement <= 0.0 {
+			return conservative
+		}
+		if yearsToRetirement <= 5.0 {
+			return moderate
+		}
+		if yearsToRetirement <= 40.0 {
+			return aggressive
+		}
+		return speculative
+	}
+}
+
+// AgeGlidePath moves between allocations pinned at ages.
+func AgeGlidePath(sim *Simulation, points []*GlidePoint) GlidePath {
+	points = sortGlidePoints(points)
+	return func(date time.Time) *Allocation {
+		return interpolateAllocation(points, sim.Actuary.Age(date))
+	}
+}
+
+// FixedGlidePath holds one allocation throughout.
+func FixedGlidePath(alloc *Allocation) GlidePath {
+	return func(date time.Time) *Allocation {
+		return alloc
+	}
+}
+
+// TargetDateGlidePath follows a target-date curve, whose points are
+// pinned at years from retirement, negative before it.
+func TargetDateGlidePath(sim *Simulation, points []*GlidePoint) GlidePath {
+	points = sortGlidePoints(points)
+	return func(date time.Time) *Allocation {
+		return interpolateAllocation(points, sim.Actuary.Age(date) - sim.RetirementAge())
+	}
+}
+
+// TargetDateCurves are the curves risk_profile.target_date can name.
+// They're shaped like common target-date funds, not copied from any:
+// one stops getting more conservative at retirement, the other keeps
+// on for seven years after.
+var TargetDateCurves = map[string][]*GlidePoint{
+	"to_retirement": {
+		{At: -25.0, Allocation: &Allocation{Stocks: 0.9, Bonds: 0.1}},
+		{At: 0.0, Allocation: &Allocation{Stocks: 0.5, Bonds: 0.45, Cash: 0.05}},
+	},
+	"through_retirement": {
+		{At: -25.0, Allocation: &Allocation{Stocks: 0.9, Bonds: 0.1}},
+		{At: 0.0, Allocation: &Allocation{Stocks: 0.5, Bonds: 0.45, Cash: 0.05}},
+		{At: 7.0, Allocation: &Allocation{Stocks: 0.3, Bonds: 0.65, Cash: 0.05}},
+	},
+}
+
 type Portfolio struct {
 	*Simulacrum
-	speculative float64
-	aggressive float64
-	moderate float64
-	conservative float64
+	glide GlidePath
+	rebalance RebalanceRule
+	location bool
+	locatedOn time.Time
+	located map[*InvestmentAccount]*Allocation
 	lastDate *time.Time
 	lastRet *float64
 }
 
-func NewPortfolio(sim *Simulation, speculative, aggressive, moderate, conservative float64) *Portfolio {
+func NewPortfolio(sim *Simulation, glide GlidePath, rebalance RebalanceRule) *Portfolio {
 	return &Portfolio{
 		Simulacrum: NewSimulacrum(sim),
-		speculative: speculative,
-		aggressive: aggressive,
-		moderate: moderate,
-		conservative: conservative,
+		glide: glide,
+		rebalance: rebalance,
 	}
 }
 
-func (p *Portfolio) riskTarget(date time.Time) float64 {
-	yearsToRetirement := p.Sim().RetirementAge() - p.Age(date)
-	if yearsToRetirement <= 0.0 {
-		return p.conservative
+// Allocation returns the target allocation on a date.
+func (p *Portfolio) Allocation(date time.Time) *Allocation {
+	return p.glide(date)
+}
+
+// Target returns the allocation an account should hold on a date.
+// That's the portfolio's allocation, unless assets are located across
+// accounts.
+func (p *Portfolio) Target(acct *InvestmentAccount, date time.Time) *Allocation {
+	target := p.Allocation(date)
+	if !p.location || target.IsRisk() {
+		return target
+	}
+	if p.located == nil || !date.Equal(p.locatedOn) {
+		p.locate(target)
+		p.locatedOn = date
 	}
-	if yearsToRetirement <= 5.0 {
-		return p.moderate
+	if alloc, ok := p.located[acct]; ok {
+		return alloc
+	}
+	return target
+}
+
+// locate spreads the target allocation over every household account
+// invested in the portfolio, so that together they hold the target.
+// Bonds and cash, whose income is taxed as ordinary income, go into
+// tax-deferred accounts first, then brokerage accounts, leaving Roth
+// accounts, where growth is never taxed, to hold stocks.
+func (p *Portfolio) locate(target *Allocation) {
+	accts := []*InvestmentAccount{}
+	var total float64 = 0.0
+	for _, acct := range p.Sim().Investments {
+		if acct.Portfolio() == p && acct.Balance() > 0.0 {
+			accts = append(accts, acct)
+			total += acct.Balance()
+		}
 	}
-	if yearsToRetirement <= 40.0 {
-		return p.aggressive
+	bonds := total * target.Bonds
+	cash := total * target.Cash
+	p.located = map[*InvestmentAccount]*Allocation{}
+	for _, match := range []AccountFilter{TaxDeferred, Brokerage, TaxFree} {
+		for _, acct := range accts {
+			if !match(acct) {
+				continue
+			}
+			bal := acct.Balance()
+			c := math.Min(cash, bal)
+			cash -= c
+			b := math.Min(bonds, bal - c)
+			bonds -= b
+			p.located[acct] = &Allocation{Stocks: (bal - b - c) / bal, Bonds: b / bal, Cash: c / bal}
+		}
 	}
-	return p.speculative
 }
 
+// AssetReturns returns the month's return on stocks, bonds and cash.
+func (p *Portfolio) AssetReturns(date time.Time) (stocks, bonds, cash float64) {
+	e := p.Sim().Economy
+	return e.MarketReturn(date) / 1200.0, e.BondReturn(date) / 1200.0, e.BillReturn(date) / 1200.0
+}
+
+// PortfolioReturn returns the month's return on a portfolio held at the
+// target allocation.
 func (p *Portfolio) PortfolioReturn(date time.Time) float64 {
-	risk := p.riskTarget(date)
+	alloc := p.Allocation(date)
+	if alloc.IsRisk() {
+		return p.riskReturn(alloc.Risk, date)
+	}
+	stocks, bonds, cash := p.AssetReturns(date)
+	return alloc.Stocks * stocks + alloc.Bonds * bonds + alloc.Cash * cash
+}
+
+// AccountReturn returns the month's return on an account as it's
+// actually invested, and lets the account's mix drift with the returns
+// on each asset.
+func (p *Portfolio) AccountReturn(acct *InvestmentAccount, date time.Time) float64 {
+	target := p.Target(acct, date)
+	if target.IsRisk() {
+		return p.riskReturn(target.Risk, date)
+	}
+	if acct.mix == nil {
+		acct.mix = target.copy()
+	}
+	mix := acct.mix
+	stocks, bonds, cash := p.AssetReturns(date)
+	ret := mix.Stocks * stocks + mix.Bonds * bonds + mix.Cash * cash
+	if ret > -1.0 {
+		mix.Stocks *= (1.0 + stocks) / (1.0 + ret)
+		mix.Bonds *= (1.0 + bonds) / (1.0 + ret)
+		mix.Cash *= (1.0 + cash) / (1.0 + ret)
+	}
+	return ret
+}
+
+// Rebalance brings an account back to the target allocation when the
+// rebalancing rule calls for it.  Selling in a brokerage account
+// realizes gains, figured at the account's average cost.
+func (p *Portfolio) Rebalance(acct *InvestmentAccount, date time.Time) {
+	target := p.Target(acct, date)
+	if target.IsRisk() || acct.mix == nil || !p.rebalance(date, acct.mix, target) {
+		return
+	}
+	bal := acct.Balance()
+	sold := bal * (math.Max(0.0, acct.mix.Stocks - target.Stocks) +
+		math.Max(0.0, acct.mix.Bonds - target.Bonds) +
+		math.Max(0.0, acct.mix.Cash - target.Cash))
+	if sold > 0.0 && Brokerage(acct) {
+		basis := acct.basisShare(sold)
+		acct.TaxMen().CapitalGain(sold - basis)
+		acct.basis += sold - basis
+	}
+	acct.mix = target.copy()
+}
+
+// riskReturn scales the stock market by a risk multiplier, with some
+// noise.  Accounts that accrue on the same date see nearly the same
+// return.
+func (p *Portfolio) riskReturn(risk float64, date time.Time) float64 {
 	if p.lastDate != nil && p.lastRet != nil && date.Equal(*p.lastDate) {
 		return *p.lastRet * (1.0 + (p.Gauss(0.0, risk * 0.05) / 12.0))
 	}
@@ -51,4 +250,3 @@ func (p *Portfolio) PortfolioReturn(date time.Time) float64 {
 	p.lastRet = &ret
 	return ret
 }
-
@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	RebalanceMonthly = "monthly"
+	RebalanceQuarterly = "quarterly"
+	RebalanceAnnual = "annual"
+	RebalanceThreshold = "threshold"
+	RebalanceNever = "never"
+)
+
+// RebalanceSchedules lists the schedules risk_profile.rebalance.schedule
+// can name.
+var RebalanceSchedules = []string{
+	RebalanceMonthly,
+	RebalanceQuarterly,
+	RebalanceAnnual,
+	RebalanceThreshold,
+	RebalanceNever,
+}
+
+// DefaultRebalanceThreshold is how far an asset can drift from its
+// target before threshold rebalancing steps in.
+const DefaultRebalanceThreshold = 0.05
+
+// RebalanceRule reports whether an account whose assets have drifted to
+// mix should be brought back to target.
+type RebalanceRule func(date time.Time, mix, target *Allocation) bool
+
+func NewRebalanceRule(config *RebalanceConfig) RebalanceRule {
+	if config == nil {
+		return CalendarRebalance(12)
+	}
+	switch config.Schedule {
+	case RebalanceMonthly:
+		return CalendarRebalance(1)
+	case RebalanceQuarterly:
+		return CalendarRebalance(3)
+	case RebalanceThreshold:
+		threshold := config.Threshold
+		if threshold == 0.0 {
+			threshold = DefaultRebalanceThreshold
+		}
+		return ThresholdRebalance(threshold)
+	case RebalanceNever:
+		return NeverRebalance
+	}
+	return CalendarRebalance(12)
+}
+
+// CalendarRebalance rebalances every so many months, counting from
+// January.
+func CalendarRebalance(months int) RebalanceRule {
+	return func(date time.Time, mix, target *Allocation) bool {
+		return (int(date.Month()) - 1) % months == 0
+	}
+}
+
+// ThresholdRebalance rebalances whenever any asset drifts more than
+// threshold from its target share.
+func ThresholdRebalance(threshold float64) RebalanceRule {
+	return func(date time.Time, mix, target *Allocation) bool {
+		return mix.Distance(target) > threshold
+	}
+}
+
+func NeverRebalance(date time.Time, mix, target *Allocation) bool {
+	return false
+}
+
+func (a *Allocation) copy() *Allocation {
+	c := *a
+	return &c
+}
+
+// Distance is the furthest any asset's share is from another
+// allocation's.
+func (a *Allocation) Distance(b *Allocation) float64 {
+	return math.Max(math.Abs(a.Stocks - b.Stocks), math.Max(math.Abs(a.Bonds - b.Bonds), math.Abs(a.Cash - b.Cash)))
+}
@@ -0,0 +1,277 @@
+package sim
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// RegimeState is one state the market can be in.  Each time the market
+// enters it, the regime's duration in months and the mean return and
+// volatility of stocks, bonds and bills, in annualized percent, are
+// drawn afresh.  Each month's return is drawn on its own around the
+// mean, so volatility is the standard deviation of those monthly draws;
+// a year's return spreads only about 1/sqrt(12) as far.
+type RegimeState struct {
+	Name string
+	Duration *gaussianInputs
+	MeanReturn *gaussianInputs
+	Volatility *gaussianInputs
+	BondReturn *gaussianInputs
+	BondVolatility *gaussianInputs
+	BillReturn *gaussianInputs
+	BillVolatility *gaussianInputs
+}
+
+// Bonds and bills in configured states that don't say otherwise.
+var (
+	DefaultBondReturn = &gaussianInputs{mean: 5.0, stddev: 1.0, min: 1.0, max: 9.0}
+	DefaultBondVolatility = fixed(1.5)
+	DefaultBillReturn = &gaussianInputs{mean: 3.0, stddev: 0.5, min: 0.0, max: 6.0}
+	DefaultBillVolatility = fixed(0.3)
+)
+
+// RegimeGraph is a set of market states, the rules for moving between
+// them, and the state the market starts in.
+type RegimeGraph struct {
+	Start string
+	states map[string]*RegimeState
+	transitions map[string]nextRegimeGetter
+}
+
+// NewRegime enters the named state.
+func (g *RegimeGraph) NewRegime(sim *Simulation, name string) *Regime {
+	return g.newRegimeLasting(sim, name, g.states[name].Duration)
+}
+
+func (g *RegimeGraph) newRegimeLasting(sim *Simulation, name string, dur *gaussianInputs) *Regime {
+	s := g.states[name]
+	r := newRegime(sim, name, dur, s.MeanReturn, s.Volatility, g.transitions[name])
+	r.state = s
+	return r
+}
+
+// States returns the names of the graph's states.
+func (g *RegimeGraph) States() []string {
+	return sortedKeys(g.states)
+}
+
+func fixed(v float64) *gaussianInputs {
+	return &gaussianInputs{mean: v, stddev: 0.0, min: v, max: v}
+}
+
+// DefaultRegimes is the market the simulation has always used: a
+// recession, recovery and expansion cycle, where a long expansion may
+// turn into a bubble, a bubble may burst into a depression, and a
+// depression is followed by years of stagnation.  Those last rules
+// depend on more than the current state, so unlike a configured graph
+// it isn't a plain transition matrix.
+var DefaultRegimes = newDefaultRegimes()
+
+func newDefaultRegimes() *RegimeGraph {
+	g := &RegimeGraph{
+		Start: "recession",
+		states: map[string]*RegimeState{
+			"recovery": {
+				Name: "recovery",
+				Duration: &gaussianInputs{mean: 12.0, stddev: 6.0, min: 6.0, max: 18.0},
+				MeanReturn: &gaussianInputs{mean: 12.0, stddev: 3.0, min: 5.0, max: math.MaxFloat64},
+				Volatility: fixed(3.0),
+				BondReturn: &gaussianInputs{mean: 4.0, stddev: 1.0, min: 1.0, max: 7.0},
+				BondVolatility: fixed(1.5),
+				BillReturn: &gaussianInputs{mean: 1.5, stddev: 0.5, min: 0.0, max: 3.0},
+				BillVolatility: fixed(0.2),
+			},
+			"expansion": {
+				Name: "expansion",
+				Duration: &gaussianInputs{mean: 72.0, stddev: 24.0, min: 48.0, max: 96.0},
+				MeanReturn: fixed(10.0),
+				Volatility: fixed(2.0),
+				BondReturn: &gaussianInputs{mean: 4.5, stddev: 1.0, min: 1.5, max: 7.5},
+				BondVolatility: fixed(1.0),
+				BillReturn: &gaussianInputs{mean: 3.5, stddev: 0.5, min: 2.0, max: 5.0},
+				BillVolatility: fixed(0.2),
+			},
+			"bubble": {
+				Name: "bubble",
+				Duration: &gaussianInputs{mean: 12.0, stddev: 6.0, min: 2.0, max: math.MaxFloat64},
+				MeanReturn: fixed(15.0),
+				Volatility: fixed(0.5),
+				BondReturn: &gaussianInputs{mean: 3.0, stddev: 1.0, min: 0.0, max: 6.0},
+				BondVolatility: fixed(1.5),
+				BillReturn: &gaussianInputs{mean: 5.0, stddev: 0.5, min: 3.5, max: 6.5},
+				BillVolatility: fixed(0.3),
+			},
+			"recession": {
+				Name: "recession",
+				Duration: &gaussianInputs{mean: 12.0, stddev: 6.0, min: 6.0, max: 24.0},
+				MeanReturn: fixed(-5.0),
+				Volatility: fixed(2.0),
+				BondReturn: &gaussianInputs{mean: 8.0, stddev: 1.0, min: 5.0, max: 11.0},
+				BondVolatility: fixed(2.0),
+				BillReturn: &gaussianInputs{mean: 2.5, stddev: 0.5, min: 1.0, max: 4.0},
+				BillVolatility: fixed(0.3),
+			},
+			"depression": {
+				Name: "depression",
+				Duration: &gaussianInputs{mean: 12.0, stddev: 3.0, min: 6.0, max: 24.0},
+				MeanReturn: &gaussianInputs{mean: -30.0, stddev: 5.0, min: -40.0, max: -20.0},
+				Volatility: fixed(5.0),
+				BondReturn: &gaussianInputs{mean: 6.0, stddev: 1.0, min: 3.0, max: 9.0},
+				BondVolatility: fixed(2.5),
+				BillReturn: &gaussianInputs{mean: 0.5, stddev: 0.5, min: 0.0, max: 2.0},
+				BillVolatility: fixed(0.2),
+			},
+			"stagnation": {
+				Name: "stagnation",
+				Duration: &gaussianInputs{mean: 24.0, stddev: 6.0, min: 12.0, max: 36.0},
+				MeanReturn: fixed(2.0),
+				Volatility: fixed(1.0),
+				BondReturn: &gaussianInputs{mean: 4.0, stddev: 1.0, min: 1.0, max: 7.0},
+				BondVolatility: fixed(1.5),
+				BillReturn: &gaussianInputs{mean: 3.0, stddev: 0.5, min: 1.5, max: 4.5},
+				BillVolatility: fixed(0.3),
+			},
+		},
+	}
+	g.transitions = map[string]nextRegimeGetter{
+		"recovery": func(r *Regime) *Regime {
+			return g.NewRegime(r.Sim(), "expansion")
+		},
+		"expansion": func(r *Regime) *Regime {
+			if r.Duration() >= 30 {
+				if r.RandTan(0.3, 10.0, 0.0, -1.0) > 0.5 {
+					return g.NewRegime(r.Sim(), "bubble")
+				}
+			}
+			return g.NewRegime(r.Sim(), "recession")
+		},
+		"bubble": func(r *Regime) *Regime {
+			if r.RandTan(0.5, 10.0, 0.0, -1.0) > 0.75 {
+				return g.NewRegime(r.Sim(), "depression")
+			}
+			return g.NewRegime(r.Sim(), "recession")
+		},
+		"recession": func(r *Regime) *Regime {
+			if r.Random() > 0.8 {
+				return g.NewRegime(r.Sim(), "stagnation")
+			}
+			return g.NewRegime(r.Sim(), "recovery")
+		},
+		"depression": func(r *Regime) *Regime {
+			sdur := math.Floor(r.ClampedGauss(48.0, 12.0, 36.0, 60.0))
+			return g.newRegimeLasting(r.Sim(), "stagnation", fixed(sdur))
+		},
+		"stagnation": func(r *Regime) *Regime {
+			return g.NewRegime(r.Sim(), "recovery")
+		},
+	}
+	return g
+}
+
+// RegimePresets lists the graphs economy.regimes.preset can name.
+var RegimePresets = map[string]*RegimeGraph{
+	"default": DefaultRegimes,
+}
+
+// NewRegimeGraph builds the regime graph a config describes: one of the
+// presets, or states with a transition matrix.  Either way start can
+// pick the state the market begins in.
+func NewRegimeGraph(config *RegimeConfig) *RegimeGraph {
+	if len(config.States) == 0 {
+		preset := DefaultRegimes
+		if config.Preset != "" {
+			preset = RegimePresets[config.Preset]
+		}
+		if config.Start == "" {
+			return preset
+		}
+		return &RegimeGraph{
+			Start: config.Start,
+			states: preset.states,
+			transitions: preset.transitions,
+		}
+	}
+	g := &RegimeGraph{
+		Start: config.Start,
+		states: map[string]*RegimeState{},
+		transitions: map[string]nextRegimeGetter{},
+	}
+	if g.Start == "" {
+		g.Start = config.States[0].Name
+	}
+	for i, sc := range config.States {
+		g.states[sc.Name] = &RegimeState{
+			Name: sc.Name,
+			Duration: sc.Duration.inputs(),
+			MeanReturn: sc.MeanReturn.inputs(),
+			Volatility: sc.Volatility.inputs(),
+			BondReturn: sc.BondReturn.inputsOr(DefaultBondReturn),
+			BondVolatility: sc.BondVolatility.inputsOr(DefaultBondVolatility),
+			BillReturn: sc.BillReturn.inputsOr(DefaultBillReturn),
+			BillVolatility: sc.BillVolatility.inputsOr(DefaultBillVolatility),
+		}
+		row := config.Transitions[i]
+		last := 0
+		for j, p := range row {
+			if p > 0.0 {
+				last = j
+			}
+		}
+		g.transitions[sc.Name] = func(r *Regime) *Regime {
+			x := r.Random()
+			for j, p := range row {
+				if x < p {
+					return g.NewRegime(r.Sim(), config.States[j].Name)
+				}
+				x -= p
+			}
+			// rounding left x just past the end of the row
+			return g.NewRegime(r.Sim(), config.States[last].Name)
+		}
+	}
+	return g
+}
+
+// DistributionConfig is a normally distributed value clamped to
+// [min, max].  A bare number in place of the object is a fixed value.
+type DistributionConfig struct {
+	Mean float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+func (c *DistributionConfig) UnmarshalJSON(data []byte) error {
+	var v float64
+	if json.Unmarshal(data, &v) == nil {
+		*c = DistributionConfig{Mean: v, Min: &v, Max: &v}
+		return nil
+	}
+	type plainDistribution DistributionConfig
+	return json.Unmarshal(data, (*plainDistribution)(c))
+}
+
+// Bounds returns the range values are clamped to, unbounded where min or
+// max isn't set.
+func (c *DistributionConfig) Bounds() (min, max float64) {
+	min, max = -math.MaxFloat64, math.MaxFloat64
+	if c.Min != nil {
+		min = *c.Min
+	}
+	if c.Max != nil {
+		max = *c.Max
+	}
+	return min, max
+}
+
+func (c *DistributionConfig) inputs() *gaussianInputs {
+	min, max := c.Bounds()
+	return &gaussianInputs{mean: c.Mean, stddev: c.StdDev, min: min, max: max}
+}
+
+func (c *DistributionConfig) inputsOr(def *gaussianInputs) *gaussianInputs {
+	if c == nil {
+		return def
+	}
+	return c.inputs()
+}
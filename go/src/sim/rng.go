@@ -4,10 +4,23 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"io"
 	"math/rand"
 	"os"
 )
 
+// SeedSource hands out the random number generators used by each
+// Simulacrum in a simulation, in the order they are created.
+type SeedSource interface {
+	Get(i int) (*rand.Rand, error)
+	Next() (*rand.Rand, error)
+	Reset()
+	Close() error
+}
+
+// SeedPod derives seeds from the contents of a file.  The seeds depend
+// on the file, so runs can only be replayed on machines with an
+// identical copy of it.
 type SeedPod struct {
 	src *os.File
 	n int
@@ -22,6 +35,7 @@ func NewSeedPod(src string, offset int64) (*SeedPod, error) {
 	}
 	info, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 	return &SeedPod{
@@ -33,23 +47,19 @@ func NewSeedPod(src string, offset int64) (*SeedPod, error) {
 }
 
 func (s *SeedPod) Get(i int) (*rand.Rand, error) {
+	// ReadAt doesn't move the file cursor, so pods can be read from
+	// several goroutines at once
 	pos := (int64(i / 4) * 32 + s.offset) % s.size
-	s.src.Seek(pos, os.SEEK_SET)
 	buf := make([]byte, 32)
-	n, err := s.src.Read(buf)
-	if err != nil {
+	n, err := s.src.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 	if n < 32 {
-		s.src.Seek(0, os.SEEK_SET)
-		xbuf := make([]byte, 32 - n)
-		_, err := s.src.Read(xbuf)
-		if err != nil {
+		_, err := s.src.ReadAt(buf[n:], 0)
+		if err != nil && err != io.EOF {
 			return nil, err
 		}
-		for j, v := range xbuf {
-			buf[n+j] = v
-		}
 	}
 	m := (i % 4) * 8
 	sum := sha256.Sum256(buf)
@@ -72,3 +82,67 @@ func (s *SeedPod) Reset() {
 	s.n = -1
 }
 
+func (s *SeedPod) Close() error {
+	return s.src.Close()
+}
+
+const goldenGamma uint64 = 0x9e3779b97f4a7c15
+
+func splitmix64(x uint64) uint64 {
+	x += goldenGamma
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// SeedStream derives seeds from a single master seed using splitmix64,
+// so a run can be replayed anywhere from the master seed and its run
+// number alone.
+type SeedStream struct {
+	seed uint64
+	n int
+}
+
+func NewSeedStream(seed int64) *SeedStream {
+	return &SeedStream{
+		seed: uint64(seed),
+		n: -1,
+	}
+}
+
+// Split derives the i-th child stream.  Child streams are independent
+// of each other and of the parent.
+func (s *SeedStream) Split(i int) *SeedStream {
+	return &SeedStream{
+		seed: splitmix64(s.seed ^ splitmix64(^uint64(i))),
+		n: -1,
+	}
+}
+
+func (s *SeedStream) Get(i int) (*rand.Rand, error) {
+	seed := splitmix64(s.seed + uint64(i) * goldenGamma)
+	source := rand.NewSource(int64(seed))
+	return rand.New(source), nil
+}
+
+func (s *SeedStream) Next() (*rand.Rand, error) {
+	s.n += 1
+	return s.Get(s.n)
+}
+
+func (s *SeedStream) Reset() {
+	s.n = -1
+}
+
+func (s *SeedStream) Close() error {
+	return nil
+}
+
+// NewSeedSource returns the seed source for run n.  Runs are seeded
+// from the config's master seed unless a seed file is configured.
+func NewSeedSource(n int, config *SimConfig) (SeedSource, error) {
+	if config.SeedFile != "" {
+		return NewSeedPod(config.SeedFile, int64(n * 1789))
+	}
+	return NewSeedStream(config.Seed).Split(n), nil
+}
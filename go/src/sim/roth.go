@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+func NewRothIRA(sim *Simulation, balance, contributions float64, openDate time.Time, name string) *InvestmentAccount {
+	a := &InvestmentAccount{
+		Simulacrum: NewSimulacrum(sim),
+		name: name + " Roth IRA",
+		ledger: NewLedger(),
+		taxable: false,
+		taxFree: true,
+	}
+	a.rmd = RothRMD(sim)
+	a.canWithdraw = RothCanWithdraw(sim)
+	a.canDeposit = RothIRACanDeposit(sim)
+	a.limit = IRAContributionLimit(sim)
+	a.limitName = "ira"
+	a.withdrawTax = EarlyWithdrawalTax(sim, RothWithdrawTax(sim, openDate), nil)
+	a.Transaction(balance, a.StartDate(), OpenAccount)
+	a.basis = contributions
+	return a
+}
+
+func NewRoth401K(sim *Simulation, balance, contributions float64, openDate time.Time, name string) *InvestmentAccount {
+	a := &InvestmentAccount{
+		Simulacrum: NewSimulacrum(sim),
+		name: name + " Roth 401k",
+		ledger: NewLedger(),
+		taxable: false,
+		taxFree: true,
+	}
+	a.rmd = RothRMD(sim)
+	a.canWithdraw = RothCanWithdraw(sim)
+	a.canDeposit = Roth401KCanDeposit(sim)
+	a.limit = K401ContributionLimit(sim)
+	a.limitName = "401k"
+	a.payroll = true
+	a.withdrawTax = EarlyWithdrawalTax(sim, RothWithdrawTax(sim, openDate), RuleOf55(sim))
+	a.Transaction(balance, a.StartDate(), OpenAccount)
+	a.basis = contributions
+	return a
+}
+
+// RothRMD never requires a distribution; owners of Roth accounts have
+// no required distributions during their lifetime.
+func RothRMD(sim *Simulation) RMD {
+	return func(date time.Time, acct Account) float64 {
+		return 0.0
+	}
+}
+
+func RothCanWithdraw(sim *Simulation) WithdrawRule {
+	return func(date time.Time) bool {
+		return true
+	}
+}
+
+// RothIRACanDeposit requires earned income, so contributions stop at
+// retirement.
+func RothIRACanDeposit(sim *Simulation) DepositRule {
+	return func(date time.Time) bool {
+		return sim.Actuary.Age(date) < sim.RetirementAge()
+	}
+}
+
+func Roth401KCanDeposit(sim *Simulation) DepositRule {
+	return func(date time.Time) bool {
+		return sim.Actuary.Age(date) < sim.RetirementAge()
+	}
+}
+
+// RothQualified reports whether a withdrawal is qualified: the owner is
+// at least 59½ and five tax years have passed since the first
+// contribution.
+func RothQualified(sim *Simulation, openDate, date time.Time) bool {
+	if sim.Actuary.Age(date) < 59.5 {
+		return false
+	}
+	return !date.Before(startOfYear(openDate).AddDate(5, 0, 0))
+}
+
+// RothWithdrawTax takes contributions out first, tax free.  Earnings
+// are tax free too if the withdrawal is qualified, and ordinary income
+// if it isn't.  Contributions are returned as basis, so wrapping this
+// in EarlyWithdrawalTax penalizes only the earnings.
+func RothWithdrawTax(sim *Simulation, openDate time.Time) WithdrawTax {
+	return func(amount float64, date time.Time, acct *InvestmentAccount) float64 {
+		basis := math.Max(0.0, math.Min(amount, acct.Basis()))
+		earnings := amount - basis
+		if earnings > 0.0 && !RothQualified(sim, openDate, date) {
+			sim.TaxMen.Withhold(earnings, date, false)
+		}
+		return basis
+	}
+}
@@ -1,10 +1,12 @@
 package sim
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -13,9 +15,52 @@ type ChildConfig struct {
 	Tuition []float64 `json:"tuition"`
 }
 
+const (
+	IRAInherited = "inherited"
+	IRATraditional = "traditional"
+)
+
+// AccountConfig is a brokerage account or 401k, or just its balance.
+// Like every investment account, it follows the household's risk
+// profile unless it has an allocation or risk_profile of its own.
+type AccountConfig struct {
+	Balance float64 `json:"balance"`
+	Allocation *AllocationConfig `json:"allocation"`
+	RiskProfile *RiskProfileConfig `json:"risk_profile"`
+}
+
+func (c *AccountConfig) UnmarshalJSON(data []byte) error {
+	var balance float64
+	if json.Unmarshal(data, &balance) == nil {
+		*c = AccountConfig{Balance: balance}
+		return nil
+	}
+	type plainAccount AccountConfig
+	return json.Unmarshal(data, (*plainAccount)(c))
+}
+
+// IRAConfig describes an IRA.  IRAs are inherited unless their type
+// says they're traditional IRAs owned outright.  The original owner's
+// birth date and whether the heir is an eligible designated beneficiary
+// decide which distribution rules an inherited IRA follows.  An owned
+// IRA can start 72(t) payments at sepp_age.
 type IRAConfig struct {
+	Type string `json:"type"`
 	Balance float64 `json:"balance"`
 	InheritDate string `json:"inherit_date"`
+	SEPPAge float64 `json:"sepp_age"`
+	OwnerBirthDate string `json:"owner_birth_date"`
+	EligibleBeneficiary bool `json:"eligible_beneficiary"`
+	Allocation *AllocationConfig `json:"allocation"`
+	RiskProfile *RiskProfileConfig `json:"risk_profile"`
+}
+
+type RothConfig struct {
+	Balance float64 `json:"balance"`
+	Contributions float64 `json:"contributions"`
+	OpenDate string `json:"open_date"`
+	Allocation *AllocationConfig `json:"allocation"`
+	RiskProfile *RiskProfileConfig `json:"risk_profile"`
 }
 
 type CarConfig struct {
@@ -36,9 +81,11 @@ type HomeConfig struct {
 
 type AssetConfig struct {
 	Cash float64 `json:"cash"`
-	SlushFund map[string]float64 `json:"slush_fund"`
-	K401 map[string]float64 `json:"401k"`
+	SlushFund map[string]*AccountConfig `json:"slush_fund"`
+	K401 map[string]*AccountConfig `json:"401k"`
 	IRA map[string]*IRAConfig `json:"ira"`
+	RothIRA map[string]*RothConfig `json:"roth_ira"`
+	Roth401K map[string]*RothConfig `json:"roth_401k"`
 	Home *HomeConfig `json:"home"`
 	Car *CarConfig `json:"car"`
 }
@@ -49,11 +96,115 @@ type DebtConfig struct {
 	DueDate string `json:"due_date"`
 }
 
+// RiskProfileConfig is how the portfolio is invested over time: more
+// than 40 years from retirement, up to 5 years out, in the last 5 years
+// and once retired; or along a glide_path of allocations by age; or
+// along one of the TargetDateCurves.  Accounts drift from the target
+// until they're rebalanced.  With asset_location, the household's
+// accounts together hold the target, with bonds and cash kept in
+// tax-deferred accounts and stocks in Roth and brokerage accounts.
 type RiskProfileConfig struct {
-	Speculative float64 `json:"speculative"`
-	Aggressive float64 `json:"aggressive"`
-	Moderate float64 `json:"moderate"`
-	Conservative float64 `json:"conservative"`
+	Speculative *AllocationConfig `json:"speculative"`
+	Aggressive *AllocationConfig `json:"aggressive"`
+	Moderate *AllocationConfig `json:"moderate"`
+	Conservative *AllocationConfig `json:"conservative"`
+	GlidePath []*GlidePointConfig `json:"glide_path"`
+	TargetDate string `json:"target_date"`
+	Rebalance *RebalanceConfig `json:"rebalance"`
+	AssetLocation bool `json:"asset_location"`
+}
+
+// GlidePointConfig is the allocation at an age along a glide path.
+type GlidePointConfig struct {
+	Age float64 `json:"age"`
+	Allocation *AllocationConfig `json:"allocation"`
+}
+
+// RebalanceConfig is when accounts are brought back to their target
+// allocation: on a schedule, by default annually, or whenever an asset
+// drifts more than threshold from its target share.
+type RebalanceConfig struct {
+	Schedule string `json:"schedule"`
+	Threshold float64 `json:"threshold"`
+}
+
+// MatchConfig is one tier of an employer match: rate of every dollar
+// deferred is matched, up to up_to of salary.
+type MatchConfig struct {
+	Rate float64 `json:"rate"`
+	UpTo float64 `json:"up_to"`
+}
+
+// K401PlanConfig sets up payroll deferrals into the 401k in assets named
+// by account, or the Roth 401k if roth is set.  Deferral is a share of
+// salary.
+type K401PlanConfig struct {
+	Account string `json:"account"`
+	Roth bool `json:"roth"`
+	Deferral float64 `json:"deferral"`
+	Match []*MatchConfig `json:"match"`
+}
+
+// SpendingConfig picks the policy that sets living expenses.  Rate is
+// the withdrawal rate the portfolio-driven policies use, or the real
+// return VPW assumes.  Floor and ceiling bound the floor_ceiling policy
+// as multiples of its first year's spending.
+type SpendingConfig struct {
+	Policy string `json:"policy"`
+	Rate float64 `json:"rate"`
+	Floor float64 `json:"floor"`
+	Ceiling float64 `json:"ceiling"`
+}
+
+// EconomyConfig picks the market model.  The historical model starts
+// each run at a different year of history unless start_year pins it.
+// The bootstrap model resamples history in blocks of block_length
+// months, rounded to whole years if the history is annual.  The regime
+// model walks the default regime graph unless regimes defines another,
+// and correlations ties its stocks, bonds and cash together.
+type EconomyConfig struct {
+	Model string `json:"model"`
+	StartYear int `json:"start_year"`
+	BlockLength int `json:"block_length"`
+	Regimes *RegimeConfig `json:"regimes"`
+	Correlations *CorrelationConfig `json:"correlations"`
+}
+
+// CorrelationConfig is how monthly surprises in the regime model's
+// stocks, bonds and cash move together.  Pairs left out are
+// uncorrelated.
+type CorrelationConfig struct {
+	StocksBonds float64 `json:"stocks_bonds"`
+	StocksCash float64 `json:"stocks_cash"`
+	BondsCash float64 `json:"bonds_cash"`
+}
+
+// RegimeConfig defines the regime graph: either a preset, or states
+// with a transition matrix where transitions[i][j] is the chance state
+// i is followed by state j.  start is the state the market begins in,
+// by default the preset's, or else the first state.  Inflation follows
+// the market for states named like the default's; other states leave
+// it moderate.
+type RegimeConfig struct {
+	Preset string `json:"preset"`
+	Start string `json:"start"`
+	States []*RegimeStateConfig `json:"states"`
+	Transitions [][]float64 `json:"transitions"`
+}
+
+// RegimeStateConfig is one market state.  Its duration is in months;
+// the mean returns of stocks, bonds and bills are annualized percent,
+// and their volatilities are the standard deviation of each month's
+// annualized return, not of a year's.  Bonds and bills are optional.
+type RegimeStateConfig struct {
+	Name string `json:"name"`
+	Duration *DistributionConfig `json:"duration"`
+	MeanReturn *DistributionConfig `json:"mean_return"`
+	Volatility *DistributionConfig `json:"volatility"`
+	BondReturn *DistributionConfig `json:"bond_return"`
+	BondVolatility *DistributionConfig `json:"bond_volatility"`
+	BillReturn *DistributionConfig `json:"bill_return"`
+	BillVolatility *DistributionConfig `json:"bill_volatility"`
 }
 
 type AssistedLivingConfig struct {
@@ -76,20 +227,40 @@ type SimConfig struct {
 	RetirementAge float64 `json:"retirement_age"`
 	SocialSecurityAge float64 `json:"social_security_age"`
 	SocialSecurityPayouts [3]float64 `json:"social_security_payouts"`
+	EarningsRecord map[string]float64 `json:"earnings_record"`
 	HealthCare *HealthCareConfig `json:"health_care"`
 	AnnualSalary float64 `json:"annual_salary"`
+	K401Plan *K401PlanConfig `json:"401k_plan"`
 	MonthlyLiving float64 `json:"monthly_living"`
+	Spending *SpendingConfig `json:"spending"`
 	Cushion float64 `json:"cushion"`
+	WithdrawalStrategy string `json:"withdrawal_strategy"`
 	Rent float64 `json:"rent"`
 	State string `json:"state"`
 	Children map[string]*ChildConfig `json:"children"`
 	Assets *AssetConfig `json:"assets"`
 	RiskProfile *RiskProfileConfig `json:"risk_profile"`
 	Debts map[string]*DebtConfig `json:"debts"`
+	Seed int64 `json:"seed"`
+	SeedFile string `json:"seed_file"`
+	StartDate string `json:"start_date"`
+	Economy *EconomyConfig `json:"economy"`
+	unknown []string
+}
+
+// Start returns the first month of the simulation.  Without a
+// start_date the simulation starts at the beginning of the current
+// month, which is the only place the wall clock is consulted.
+func (c *SimConfig) Start() time.Time {
+	start, err := time.ParseInLocation("2006-01-02", c.StartDate, time.Local)
+	if err != nil {
+		start = time.Now()
+	}
+	return startOfMonth(start)
 }
 
 type Simulation struct {
-	seedPod *SeedPod
+	seeds SeedSource
 	hasRun bool
 	startDate time.Time
 	config *SimConfig
@@ -109,6 +280,8 @@ type Simulation struct {
 	Car *Car
 	Debts []*Debt
 	Investments []*InvestmentAccount
+	Withdrawals WithdrawalStrategy
+	Spending SpendingPolicy
 	Children []*Child
 	Market float64
 	/*
@@ -125,35 +298,27 @@ type Simulation struct {
 	Events *EventList
 }
 
-func NewSimulation(n int, config *SimConfig) *Simulation {
-	seedPod, _ := NewSeedPod("/usr/share/dict/words", int64(n * 1789))
-	start := startOfMonth(time.Now())
+func NewSimulation(n int, config *SimConfig) (*Simulation, error) {
+	seeds, err := NewSeedSource(n, config)
+	if err != nil {
+		return nil, err
+	}
+	start := config.Start()
 	s := &Simulation{
-		seedPod: seedPod,
+		seeds: seeds,
 		hasRun: false,
 		startDate: start,
 		config: config,
 		Name: config.Name,
 	}
-	s.Economy = NewEconomy(s)
+	s.Economy = NewEconomy(s, n, config.Economy)
 	s.Portfolio = s.configurePortfolio(config.RiskProfile)
 	birthDate, _ := time.ParseInLocation("2006-01-02", config.BirthDate, time.Local)
 	s.Actuary = NewActuary(s, birthDate, config.RiskFactors)
 	s.CashAccount = NewCashAccount(s, config.Assets.Cash)
 	s.TaxMen = NewTaxMen(s, config.State)
 	s.Job = NewJob(s, config.AnnualSalary)
-	var payout float64 = 0.0
-	var age float64 = 62.0
-	if config.SocialSecurityAge >= 70.0 {
-		payout = config.SocialSecurityPayouts[2]
-		age = config.SocialSecurityAge
-	} else if config.SocialSecurityAge >= 67.0 {
-		payout = config.SocialSecurityPayouts[1]
-		age = config.SocialSecurityAge
-	} else {
-		payout = config.SocialSecurityPayouts[0]
-	}
-	s.SocialSecurity = NewSocialSecurity(s, age, payout)
+	s.SocialSecurity = s.configureSocialSecurity(config)
 
 	s.HealthCare = s.configureHealthCare(config.HealthCare)
 	if config.HealthCare != nil {
@@ -165,18 +330,22 @@ func NewSimulation(n int, config *SimConfig) *Simulation {
 	s.Car = s.configureCar(config.Assets.Car)
 	s.Debts = s.configureDebts(config.Debts)
 	s.Investments = s.configureInvestments(config.Assets)
+	s.Job.Plan = s.configureK401Plan(config.K401Plan)
+	s.Withdrawals = NewWithdrawalStrategy(s, config.WithdrawalStrategy)
+	s.Spending = NewSpendingPolicy(s, config.MonthlyLiving, config.Spending)
 	s.Children = s.configureChildren(config.Children)
 	el := EventList([]*Event{})
 	s.Events = &el
 	if config.Spouse != nil {
 		s.Spouse = s.configureSpouse(config.Spouse)
+		s.SocialSecurity.Marry(s.Spouse.SocialSecurity)
 	}
-	return s
+	return s, nil
 }
 
 func (s *Simulation) configureSpouse(config *SimConfig) *Simulation {
 	sim := &Simulation{
-		seedPod: s.seedPod,
+		seeds: s.seeds,
 		hasRun: false,
 		startDate: s.startDate,
 		config: config,
@@ -187,6 +356,32 @@ func (s *Simulation) configureSpouse(config *SimConfig) *Simulation {
 		Events: s.Events,
 	}
 	birthDate, _ := time.ParseInLocation("2006-01-02", config.BirthDate, time.Local)
+	sim.Actuary = NewActuary(sim, birthDate, config.RiskFactors)
+	sim.TaxMen = NewJointTaxMen(sim, s.TaxMen)
+	sim.Job = NewJob(sim, config.AnnualSalary)
+	sim.SocialSecurity = sim.configureSocialSecurity(config)
+	sim.HealthCare = sim.configureHealthCare(config.HealthCare)
+	sim.Car = sim.configureCar(config.Assets.Car)
+	// the spouse's accounts follow the spouse's own rules, but they're
+	// managed with the rest of the household's
+	sim.Investments = sim.configureInvestments(config.Assets)
+	sim.Job.Plan = sim.configureK401Plan(config.K401Plan)
+	s.Investments = append(s.Investments, sim.Investments...)
+	return sim
+}
+
+// configureSocialSecurity figures benefits from the earnings record if
+// there is one.  Otherwise it picks from the payouts at 62, 67 and 70,
+// and the one at 67 stands in for the PIA.
+func (s *Simulation) configureSocialSecurity(config *SimConfig) *SocialSecurity {
+	if config.EarningsRecord != nil {
+		earnings := map[int]float64{}
+		for year, amount := range config.EarningsRecord {
+			y, _ := strconv.Atoi(year)
+			earnings[y] = amount
+		}
+		return NewEarnedSocialSecurity(s, config.SocialSecurityAge, earnings)
+	}
 	var payout float64 = 0.0
 	var age float64 = 62.0
 	if config.SocialSecurityAge >= 70.0 {
@@ -198,43 +393,144 @@ func (s *Simulation) configureSpouse(config *SimConfig) *Simulation {
 	} else {
 		payout = config.SocialSecurityPayouts[0]
 	}
-	sim.Actuary = NewActuary(sim, birthDate, config.RiskFactors)
-	sim.TaxMen = NewTaxMen(sim, s.config.State)
-	sim.Job = NewJob(sim, config.AnnualSalary)
-	sim.SocialSecurity = NewSocialSecurity(sim, age, payout)
-	sim.HealthCare = sim.configureHealthCare(config.HealthCare)
-	sim.Car = sim.configureCar(config.Assets.Car)
-	return sim
+	ss := NewSocialSecurity(s, age, payout)
+	ss.fullPayout = config.SocialSecurityPayouts[1]
+	return ss
 }
 
 func (s *Simulation) configurePortfolio(config *RiskProfileConfig) *Portfolio {
-	return NewPortfolio(s, config.Speculative, config.Aggressive, config.Moderate, config.Conservative)
+	var glide GlidePath
+	if len(config.GlidePath) > 0 {
+		points := make([]*GlidePoint, len(config.GlidePath))
+		for i, p := range config.GlidePath {
+			points[i] = &GlidePoint{At: p.Age, Allocation: p.Allocation.Allocation()}
+		}
+		glide = AgeGlidePath(s, points)
+	} else if config.TargetDate != "" {
+		glide = TargetDateGlidePath(s, TargetDateCurves[config.TargetDate])
+	} else {
+		glide = StagedGlidePath(s,
+			config.Speculative.Allocation(),
+			config.Aggressive.Allocation(),
+			config.Moderate.Allocation(),
+			config.Conservative.Allocation())
+	}
+	p := NewPortfolio(s, glide, NewRebalanceRule(config.Rebalance))
+	p.location = config.AssetLocation
+	return p
+}
+
+// configureAccountPortfolio returns the portfolio for an account that's
+// invested on its own, or nil for one that follows the household's.
+func (s *Simulation) configureAccountPortfolio(alloc *AllocationConfig, profile *RiskProfileConfig) *Portfolio {
+	if profile != nil {
+		return s.configurePortfolio(profile)
+	}
+	if alloc != nil {
+		return NewPortfolio(s, FixedGlidePath(alloc.Allocation()), s.Portfolio.rebalance)
+	}
+	return nil
 }
 
 func (s *Simulation) configureInvestments(config *AssetConfig) []*InvestmentAccount {
 	accounts := []*InvestmentAccount{}
-	for name, bal := range config.SlushFund {
-		accounts = append(accounts, NewInvestmentAccount(s, bal, name))
-	}
-	for name, bal := range config.K401 {
-		accounts = append(accounts, New401K(s, bal, name))
+	// accounts with contribution limits come first so surplus cash
+	// fills them up before going anywhere else
+	for _, name := range sortedKeys(config.RothIRA) {
+		cfg := config.RothIRA[name]
+		acct := NewRothIRA(s, cfg.Balance, cfg.Contributions, s.configureOpenDate(cfg), name)
+		acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+		accounts = append(accounts, acct)
+	}
+	for _, name := range sortedKeys(config.Roth401K) {
+		cfg := config.Roth401K[name]
+		acct := NewRoth401K(s, cfg.Balance, cfg.Contributions, s.configureOpenDate(cfg), name)
+		acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+		accounts = append(accounts, acct)
+	}
+	for _, name := range sortedKeys(config.IRA) {
+		cfg := config.IRA[name]
+		if cfg.Type == IRATraditional {
+			acct := NewIRA(s, cfg.Balance, cfg.SEPPAge, name)
+			acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+			accounts = append(accounts, acct)
+		}
 	}
-	for name, cfg := range config.IRA {
+	for _, name := range sortedKeys(config.SlushFund) {
+		cfg := config.SlushFund[name]
+		acct := NewInvestmentAccount(s, cfg.Balance, name)
+		acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+		accounts = append(accounts, acct)
+	}
+	for _, name := range sortedKeys(config.K401) {
+		cfg := config.K401[name]
+		acct := New401K(s, cfg.Balance, name)
+		acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+		accounts = append(accounts, acct)
+	}
+	for _, name := range sortedKeys(config.IRA) {
+		cfg := config.IRA[name]
+		if cfg.Type == IRATraditional {
+			continue
+		}
 		inheritDate, err := time.ParseInLocation("2006-01-02", cfg.InheritDate, time.Local)
 		if err != nil {
 			inheritDate = s.Actuary.BirthDate
 		}
-		accounts = append(accounts, NewInheritedIRA(s, cfg.Balance, inheritDate, name))
+		var ownerBirthDate time.Time
+		if cfg.OwnerBirthDate != "" {
+			ownerBirthDate, _ = time.ParseInLocation("2006-01-02", cfg.OwnerBirthDate, time.Local)
+		}
+		acct := NewInheritedIRA(s, cfg.Balance, inheritDate, ownerBirthDate, cfg.EligibleBeneficiary, name)
+		acct.portfolio = s.configureAccountPortfolio(cfg.Allocation, cfg.RiskProfile)
+		accounts = append(accounts, acct)
 	}
 	return accounts
 }
 
+func (s *Simulation) configureK401Plan(config *K401PlanConfig) *K401Plan {
+	if config == nil {
+		return nil
+	}
+	name := config.Account + " 401k"
+	if config.Roth {
+		name = config.Account + " Roth 401k"
+	}
+	var acct *InvestmentAccount
+	for _, a := range s.Investments {
+		if a.Name() == name {
+			acct = a
+		}
+	}
+	if acct == nil {
+		return nil
+	}
+	plan := &K401Plan{
+		Account: acct,
+		Deferral: config.Deferral,
+		Match: []*MatchTier{},
+	}
+	for _, m := range config.Match {
+		plan.Match = append(plan.Match, &MatchTier{Rate: m.Rate, UpTo: m.UpTo})
+	}
+	return plan
+}
+
+func (s *Simulation) configureOpenDate(config *RothConfig) time.Time {
+	openDate, err := time.ParseInLocation("2006-01-02", config.OpenDate, time.Local)
+	if err != nil {
+		return s.StartDate()
+	}
+	return openDate
+}
+
 func (s *Simulation) configureDebts(config map[string]*DebtConfig) []*Debt {
 	ds := []*Debt{}
-	for name, cfg := range config {
+	for _, name := range sortedKeys(config) {
+		cfg := config[name]
 		dueDate, err := time.ParseInLocation("2006-01-02", cfg.DueDate, time.Local)
 		if err != nil {
-			dueDate = time.Now()
+			dueDate = s.StartDate()
 		}
 		ds = append(ds, NewDebt(s, cfg.Principal, cfg.Interest, dueDate, name))
 	}
@@ -247,7 +543,7 @@ func (s *Simulation) configureMortgage(config *HomeConfig) *Debt {
 	}
 	dueDate, err := time.ParseInLocation("2006-01-02", config.DueDate, time.Local)
 	if err != nil {
-		dueDate = time.Now()
+		dueDate = s.StartDate()
 	}
 	return NewMortgage(s, config.Principal, config.Interest, dueDate, "Home")
 }
@@ -266,7 +562,7 @@ func (s *Simulation) configureCarLoan(config *CarConfig) *Debt {
 	}
 	dueDate, err := time.ParseInLocation("2006-01-02", config.DueDate, time.Local)
 	if err != nil {
-		dueDate = time.Now()
+		dueDate = s.StartDate()
 	}
 	return NewDebt(s, config.Principal, config.Interest, dueDate, "Car Loan")
 }
@@ -274,21 +570,22 @@ func (s *Simulation) configureCarLoan(config *CarConfig) *Debt {
 func (s *Simulation) configureCar(config *CarConfig) *Car {
 	loan := s.configureCarLoan(config)
 	if config == nil {
-		return NewCar(s, 0.0, time.Now(), loan)
+		return NewCar(s, 0.0, s.StartDate(), loan)
 	}
 	purchaseDate, err := time.ParseInLocation("2006-01-02", config.PurchaseDate, time.Local)
 	if err != nil {
-		purchaseDate = time.Now()
+		purchaseDate = s.StartDate()
 	}
 	return NewCar(s, config.Price, purchaseDate, loan)
 }
 
 func (s *Simulation) configureChildren(config map[string]*ChildConfig) []*Child {
 	cs := []*Child{}
-	for name, cfg := range config {
+	for _, name := range sortedKeys(config) {
+		cfg := config[name]
 		bd, err := time.ParseInLocation("2006-01-02", cfg.BirthDate, time.Local)
 		if err != nil {
-			bd = time.Now()
+			bd = s.StartDate()
 		}
 		cs = append(cs, NewChild(s, name, bd, cfg.Tuition))
 	}
@@ -310,7 +607,7 @@ func (s *Simulation) configureHealthCare(config *HealthCareConfig) *HealthCare {
 }
 
 func (s *Simulation) Rng() (*rand.Rand, error) {
-	return s.seedPod.Next()
+	return s.seeds.Next()
 }
 
 func (s *Simulation) StartDate() time.Time {
@@ -362,7 +659,8 @@ func (s *Simulation) run() {
 		}
 		s.HealthCare.Monthly(date)
 		s.AssistedLiving.Monthly(date)
-		s.CashAccount.Withdraw(s.config.MonthlyLiving, date, "Monthly Expenses")
+		spending := s.Spending.Monthly(date)
+		s.CashAccount.Withdraw(spending, date, "Monthly Expenses")
 		if s.Spouse != nil && date.Before(s.Spouse.Actuary.DeathDate) {
 			if date.Month() == time.January {
 				s.Spouse.TaxMen.Annual(date)
@@ -374,7 +672,9 @@ func (s *Simulation) run() {
 		}
 		if date.Month() == time.December {
 			for _, acct := range s.Investments {
+				acct.TakeSEPP(date)
 				acct.TakeRMD(date)
+				acct.PayDividends(date)
 			}
 		}
 		surplus += (s.CashAccount.Balance() - prev)
@@ -382,76 +682,19 @@ func (s *Simulation) run() {
 		if surplus > 0.0 && date.Month() == s.StartDate().Month() && !date.Equal(s.StartDate()) {
 			// move cash to investments
 			for _, acct := range s.Investments {
-				if !acct.Taxable() && acct.CanDeposit(date) {
-					acct.Deposit(surplus, date, "Invest")
-					surplus = 0.0
+				if surplus <= 0.0 {
 					break
 				}
-			}
-		}
-
-		// top off cash account from invetments, favoring those with
-		// the largest RMDs
-		for s.CashAccount.Balance() < s.config.Cushion  * 0.75 {
-			tgt := s.config.Cushion - s.CashAccount.Balance()
-			var maxRmd float64
-			var maxRmdAcct *InvestmentAccount
-			for _, acct := range s.Investments {
-				rmd := acct.RMD(date)
-				if rmd > 0.0 {
-					if maxRmdAcct == nil || rmd > maxRmd {
-						maxRmd = rmd
-						maxRmdAcct = acct
+				if (!acct.Taxable() || acct.Deductible()) && acct.CanDeposit(date) {
+					t, _ := acct.Deposit(surplus, date, "Invest")
+					if t != nil {
+						surplus -= t.Amount
 					}
 				}
 			}
-			if maxRmdAcct == nil {
-				break
-			}
-			if tgt > maxRmd {
-				tgt = maxRmd
-			}
-			maxRmdAcct.Withdraw(tgt, date, "Cushion")
 		}
 
-		// top off cash account from investments, favoring untaxed
-		for s.CashAccount.Balance() < s.config.Cushion * 0.75 {
-			tgt := s.config.Cushion - s.CashAccount.Balance()
-			found := false
-			for _, acct := range s.Investments {
-				if !acct.CanWithdraw(date) {
-					continue
-				}
-				if acct.Taxable() {
-					continue
-				}
-				if acct.Balance() > 0.0 {
-					found = true
-					acct.Withdraw(tgt, date, "Cushion")
-					break
-				}
-			}
-			if !found {
-				break
-			}
-		}
-		for s.CashAccount.Balance() < s.config.Cushion * 0.75 {
-			tgt := s.config.Cushion - s.CashAccount.Balance()
-			found := false
-			for _, acct := range s.Investments {
-				if !acct.CanWithdraw(date) {
-					continue
-				}
-				if acct.Balance() > 0.0 {
-					found = true
-					acct.Withdraw(tgt, date, "Cushion")
-					break
-				}
-			}
-			if !found {
-				break
-			}
-		}
+		s.Withdrawals.TopOff(date)
 
 		// top off cash account by selling house
 		if s.CashAccount.Balance() < s.config.Cushion * 0.25 {
@@ -466,11 +709,15 @@ func (s *Simulation) run() {
 			amt := s.CashAccount.Balance() - minBalance
 			if amt > 0.0 {
 				for _, acct := range s.Investments {
-					if !acct.Taxable() && acct.CanDeposit(date) {
-						acct.Deposit(amt, date, "ReInvest")
-						amt = 0.0
+					if amt <= 0.0 {
 						break
 					}
+					if (!acct.Taxable() || acct.Deductible()) && acct.CanDeposit(date) {
+						t, _ := acct.Deposit(amt, date, "ReInvest")
+						if t != nil {
+							amt -= t.Amount
+						}
+					}
 				}
 			}
 		}
@@ -480,7 +727,9 @@ func (s *Simulation) run() {
 		}
 		s.Market *= (1.0 + s.Economy.MarketReturn(date) / 1200.0)
 		s.CashAccount.Reconcile()
-		s.BalanceHistory = append(s.BalanceHistory, s.Balances(date))
+		bd := s.Balances(date)
+		bd.Spending = round2(spending)
+		s.BalanceHistory = append(s.BalanceHistory, bd)
 		if !busted && s.Balance() <= 0.0 {
 			s.Events.Add(date, "Bankruptcy", 10)
 			busted = true
@@ -501,6 +750,9 @@ func (s *Simulation) run() {
 		}
 	}
 	s.Events.Add(s.Actuary.DeathDate, "Death", 10)
+	if h, ok := s.Economy.model.(*HistoricalModel); ok {
+		s.Events.Add(s.StartDate(), fmt.Sprintf("Replaying %d Market", h.StartYear), 3)
+	}
 	if s.Spouse != nil && s.Spouse.Actuary.DeathDate.Before(s.Actuary.DeathDate) {
 		s.Events.Add(s.Spouse.Actuary.DeathDate, s.Spouse.Name + "'s Death", 10)
 	}
@@ -529,15 +781,34 @@ func (s *Simulation) Balance() float64 {
 	return balance
 }
 
+// BalanceData is a month-end snapshot in nominal dollars.  PriceIndex
+// is the price level at the time, relative to the start.
 type BalanceData struct {
 	Date string `json:"date"`
+	PriceIndex float64 `json:"price_index"`
+	Spending float64 `json:"spending"`
 	Balances map[string]float64 `json:"balances"`
 }
 
+// Real returns the snapshot in today's dollars.
+func (bd *BalanceData) Real() *BalanceData {
+	real := &BalanceData{
+		Date: bd.Date,
+		PriceIndex: bd.PriceIndex,
+		Spending: round2(bd.Spending / bd.PriceIndex),
+		Balances: map[string]float64{},
+	}
+	for k, v := range bd.Balances {
+		real.Balances[k] = round2(v / bd.PriceIndex)
+	}
+	return real
+}
+
 func (s *Simulation) Balances(date time.Time) *BalanceData {
 	date = endOfMonth(date)
 	bd := &BalanceData{
 		Date: date.Format("2006-01-02"),
+		PriceIndex: s.Economy.PriceIndex(date.AddDate(0, 0, 1)),
 		Balances: map[string]float64{},
 	}
 	if s.Home != nil {
@@ -595,6 +866,7 @@ type Results struct {
 	Index int `json:"index"`
 	Age float64 `json:"death"`
 	Balance float64 `json:"balance"`
+	RealBalance float64 `json:"real_balance"`
 	Market float64 `json:"market"`
 	Transactions *Ledger `json:"transactions,omitempty"`
 	Balances []*BalanceData `json:"balances,omitempty"`
@@ -605,12 +877,14 @@ func (s *Simulation) Results() *Results {
 	if !s.hasRun {
 		s.run()
 		s.hasRun = true
+		s.seeds.Close()
 	}
 	balance := s.Balance()
 	lia := s.Liabilities(s.Actuary.DeathDate)
 	return &Results{
 		Age: s.Actuary.DeathAge(),
 		Balance: balance - lia,
+		RealBalance: (balance - lia) / s.Economy.PriceIndex(s.Actuary.DeathDate),
 		Market: s.Market,
 		Transactions: s.CashAccount.Transactions(nil, nil),
 		Balances: s.BalanceHistory,
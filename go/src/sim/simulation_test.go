@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+)
+
+// testConfig reads the sample household in testdata, which pins the
+// start date and master seed.
+func testConfig(t *testing.T) *SimConfig {
+	data, err := ioutil.ReadFile("testdata/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &SimConfig{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func testSimulation(t *testing.T, config *SimConfig) *Simulation {
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSimulation(0, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// TestGoldenRun pins the outcome of the first few runs of the sample
+// household.  A change that moves these should be one that means to.
+func TestGoldenRun(t *testing.T) {
+	golden := []struct {
+		n int
+		age float64
+		balance float64
+		market float64
+	}{
+		{0, 92.1692, 22736409.30, 0.0827},
+		{1, 75.7680, 6379362.13, 0.0800},
+		{2, 85.6031, 12225271.94, 0.0820},
+	}
+	for _, g := range golden {
+		s, err := NewSimulation(g.n, testConfig(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := s.Results()
+		if math.Abs(r.Age - g.age) > 0.0001 ||
+			math.Abs(r.Balance - g.balance) > 0.01 ||
+			math.Abs(r.Market - g.market) > 0.0001 {
+			t.Errorf("run %d: got age %.4f, balance %.2f, market %.4f; want %.4f, %.2f, %.4f",
+				g.n, r.Age, r.Balance, r.Market, g.age, g.balance, g.market)
+		}
+	}
+}
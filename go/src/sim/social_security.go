@@ -1,40 +1,441 @@
 package sim
 
 import (
+	_ "embed"
+	"encoding/csv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// RealWageGrowth is how much faster than prices average wages are
+// assumed to grow past the published wage index, about what the Social
+// Security trustees assume.
+const RealWageGrowth = 0.011
+
+// Benefits can be claimed from 62 through 70.
+const (
+	EarliestClaimingAge = 62.0
+	LatestClaimingAge = 70.0
+)
+
+//go:embed wages.csv
+var wagesCSV []byte
+
+// AverageWageIndex and TaxableMaximum are the SSA's national average
+// wage index and contribution and benefit base, by year.
+var (
+	AverageWageIndex = map[int]float64{}
+	TaxableMaximum = map[int]float64{}
+)
+
+var lastWageIndexYear, lastTaxableMaximumYear int
+
+func init() {
+	r := csv.NewReader(strings.NewReader(string(wagesCSV)))
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, row := range rows[1:] {
+		year, err := strconv.Atoi(row[0])
+		if err != nil {
+			panic(err)
+		}
+		if row[1] != "" {
+			AverageWageIndex[year], err = strconv.ParseFloat(row[1], 64)
+			if err != nil {
+				panic(err)
+			}
+			lastWageIndexYear = year
+		}
+		TaxableMaximum[year], err = strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			panic(err)
+		}
+		lastTaxableMaximumYear = year
+	}
+}
+
+// FullRetirementAge returns the age, in years, at which someone born on
+// birthDate gets their whole benefit.  Like the SSA, it counts people
+// born on January 1st with the year before, since they attain each age
+// the day before their birthday.
+func FullRetirementAge(birthDate time.Time) float64 {
+	y := birthDate.AddDate(0, 0, -1).Year()
+	switch {
+	case y <= 1937:
+		return 65.0
+	case y <= 1942:
+		return 65.0 + float64(y - 1937) * 2.0 / 12.0
+	case y <= 1954:
+		return 66.0
+	case y <= 1959:
+		return 66.0 + float64(y - 1954) * 2.0 / 12.0
+	}
+	return 67.0
+}
+
+// DelayedRetirementCredit returns how much benefits grow for each year
+// they're put off past full retirement age.
+func DelayedRetirementCredit(birthDate time.Time) float64 {
+	y := birthDate.AddDate(0, 0, -1).Year()
+	if y >= 1943 {
+		return 0.08
+	}
+	if y < 1925 {
+		return 0.03
+	}
+	return 0.035 + 0.005 * float64((y - 1925) / 2)
+}
+
+// ClaimingAdjustment is what the PIA is multiplied by for benefits that
+// start months after full retirement age, or before it if months is
+// negative.  Early benefits lose 5/9 of 1% for each of the first 36
+// months and 5/12 of 1% for each month past that.
+func ClaimingAdjustment(months int, credit float64) float64 {
+	if months >= 0 {
+		return 1.0 + credit / 12.0 * float64(months)
+	}
+	early := float64(-months)
+	return 1.0 - 5.0 / 900.0 * math.Min(early, 36.0) - 5.0 / 1200.0 * math.Max(0.0, early - 36.0)
+}
+
+// SpousalAdjustment is what a spousal benefit is multiplied by when it
+// starts months before full retirement age: 25/36 of 1% for each of the
+// first 36 months and 5/12 of 1% for each month past that.  There are
+// no delayed credits on spousal benefits.
+func SpousalAdjustment(months int) float64 {
+	if months >= 0 {
+		return 1.0
+	}
+	early := float64(-months)
+	return 1.0 - 25.0 / 3600.0 * math.Min(early, 36.0) - 5.0 / 1200.0 * math.Max(0.0, early - 36.0)
+}
+
+// SurvivorFullRetirementAge is the full retirement age for survivor
+// benefits, which follows the same schedule two years later.
+func SurvivorFullRetirementAge(birthDate time.Time) float64 {
+	return FullRetirementAge(birthDate.AddDate(-2, 0, 0))
+}
+
+// SurvivorAdjustment is what a survivor benefit is multiplied by when it
+// starts months before the survivor's full retirement age, fra in
+// months.  Taking it at 60 pays 71.5%, and the reduction shrinks evenly
+// from there.
+func SurvivorAdjustment(months, fra int) float64 {
+	if months >= 0 {
+		return 1.0
+	}
+	return 1.0 - 0.285 * float64(-months) / float64(fra - 720)
+}
+
+// monthsBetween counts whole months from one date to another, negative
+// if to comes first.
+func monthsBetween(from, to time.Time) int {
+	n := (to.Year() - from.Year()) * 12 + int(to.Month()) - int(from.Month())
+	if n > 0 && to.Day() < from.Day() {
+		n--
+	} else if n < 0 && to.Day() > from.Day() {
+		n++
+	}
+	return n
+}
+
+// SocialSecurity pays a worker's retirement benefit.  With an earnings
+// record, the benefit is figured the way the SSA does: earnings up to
+// each year's taxable maximum are indexed to wage growth through age 60,
+// the best 35 years are averaged into the AIME, the bend points turn
+// that into the PIA, and the PIA is reduced for claiming before full
+// retirement age or raised for claiming after.  Wages the simulation
+// earns are added to the record, and the benefit is refigured each year.
+// Without a record it pays a fixed payout.  Either way benefits get a
+// COLA each year from the simulation's inflation; for years before the
+// simulation starts, that's extrapolated from its first month.
+//
+// A married worker whose partner has claimed also gets a spousal benefit
+// topping their own up to half the partner's PIA, and once the partner
+// dies gets the larger of their own benefit and a survivor benefit
+// based on the partner's.  Both are paid from the worker's own claiming
+// date.  The earnings test isn't modeled.
 type SocialSecurity struct {
 	*Simulacrum
 	age float64
 	payout float64
+	fullPayout float64
+	earnings map[int]float64
+	wageIndex map[int]float64
+	partner *SocialSecurity
+	year int
+	pia float64
+	benefit float64
 }
 
+// NewSocialSecurity pays payout, in the simulation's first year's
+// dollars, from age on.  The payout also stands in for the PIA.
 func NewSocialSecurity(sim *Simulation, age, payout float64) *SocialSecurity {
 	return &SocialSecurity{
 		Simulacrum: NewSimulacrum(sim),
 		age: age,
 		payout: payout,
+		fullPayout: payout,
+		wageIndex: map[int]float64{},
+	}
+}
+
+// NewEarnedSocialSecurity figures the benefit from an earnings record,
+// by year, for benefits claimed at age.
+func NewEarnedSocialSecurity(sim *Simulation, age float64, earnings map[int]float64) *SocialSecurity {
+	s := NewSocialSecurity(sim, age, 0.0)
+	s.earnings = map[int]float64{}
+	for year, amount := range earnings {
+		s.earnings[year] = amount
 	}
+	return s
+}
+
+// Marry lets two workers draw spousal and survivor benefits on each
+// other's records.
+func (s *SocialSecurity) Marry(partner *SocialSecurity) {
+	s.partner = partner
+	partner.partner = s
 }
 
 func (s *SocialSecurity) BenefitsDate() time.Time {
+	if s.earnings != nil {
+		return s.Sim().Actuary.BirthDate.AddDate(0, int(math.Round(s.age * 12.0)), 0)
+	}
 	yf := s.age
 	yi := int(yf)
 	d := int(365.25 * (yf - float64(yi)))
 	return s.Sim().Actuary.BirthDate.AddDate(yi, 0, d)
 }
 
+// Credit adds wages to the earnings record.
+func (s *SocialSecurity) Credit(amount float64, date time.Time) {
+	if s.earnings == nil || amount <= 0.0 {
+		return
+	}
+	s.earnings[date.Year()] += amount
+}
+
+// EligibilityYear is the year the worker turns 62.
+func (s *SocialSecurity) EligibilityYear() int {
+	return s.Sim().Actuary.BirthDate.AddDate(0, 0, -1).Year() + 62
+}
+
+// WageIndex returns the average wage index for a year.  Past the
+// published years, it grows with the simulation's inflation plus
+// RealWageGrowth.
+func (s *SocialSecurity) WageIndex(year int) float64 {
+	if v, ok := AverageWageIndex[year]; ok {
+		return v
+	}
+	if year < lastWageIndexYear {
+		return AverageWageIndex[1951]
+	}
+	if v, ok := s.wageIndex[year]; ok {
+		return v
+	}
+	from := time.Date(lastWageIndexYear, time.July, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(year, time.July, 1, 0, 0, 0, 0, time.Local)
+	v := AverageWageIndex[lastWageIndexYear] * s.Sim().Economy.Inflator(from, to) * math.Pow(1.0 + RealWageGrowth, float64(year - lastWageIndexYear))
+	s.wageIndex[year] = v
+	return v
+}
+
+// TaxableMaximum returns the most a year's earnings count for.  Past
+// the published years it follows the wage index the way the law sets
+// it, rounded to $300, and never falls.
+func (s *SocialSecurity) TaxableMaximum(year int) float64 {
+	if v, ok := TaxableMaximum[year]; ok {
+		return v
+	}
+	if year < lastTaxableMaximumYear {
+		return 0.0
+	}
+	base := math.Round(60600.0 * s.WageIndex(year - 2) / AverageWageIndex[1992] / 300.0) * 300.0
+	return math.Max(s.TaxableMaximum(year - 1), base)
+}
+
+// AIME returns the average indexed monthly earnings from the record
+// through a year.
+func (s *SocialSecurity) AIME(through int) float64 {
+	indexYear := s.EligibilityYear() - 2
+	years := []float64{}
+	for year, amount := range s.earnings {
+		if year > through {
+			continue
+		}
+		amount = math.Min(amount, s.TaxableMaximum(year))
+		if year < indexYear {
+			amount *= s.WageIndex(indexYear) / s.WageIndex(year)
+		}
+		years = append(years, amount)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(years)))
+	var total float64 = 0.0
+	for i := 0; i < len(years) && i < 35; i++ {
+		total += years[i]
+	}
+	return math.Floor(total / 420.0)
+}
+
+// BendPoints returns the AIME where the PIA formula steps down from 90%
+// to 32%, and from 32% to 15%.
+func (s *SocialSecurity) BendPoints() (float64, float64) {
+	f := s.WageIndex(s.EligibilityYear() - 2) / AverageWageIndex[1977]
+	return math.Round(180.0 * f), math.Round(1085.0 * f)
+}
+
+// PIA returns the primary insurance amount in a year: the benefit at
+// full retirement age, figured from earnings through the year before,
+// with the COLAs since turning 62.
+func (s *SocialSecurity) PIA(year int) float64 {
+	if s.earnings == nil {
+		return s.fullPayout * s.cola(s.StartDate().Year(), year)
+	}
+	aime := s.AIME(year - 1)
+	bp1, bp2 := s.BendPoints()
+	pia := 0.9 * math.Min(aime, bp1) +
+		0.32 * math.Max(0.0, math.Min(aime, bp2) - bp1) +
+		0.15 * math.Max(0.0, aime - bp2)
+	return math.Floor(pia * 10.0) / 10.0 * s.cola(s.EligibilityYear(), year)
+}
+
+// cola returns how much benefits have grown by a year with the COLAs
+// since a base year.  Each December's COLA is the change in prices from
+// the third quarter of the year of the last COLA, and there's no COLA
+// at all when prices fall.
+func (s *SocialSecurity) cola(from, year int) float64 {
+	e := s.Sim().Economy
+	september := func(y int) time.Time {
+		return time.Date(y, time.September, 1, 0, 0, 0, 0, time.Local)
+	}
+	base := september(from - 1)
+	f := 1.0
+	for y := from; y < year; y++ {
+		r := e.Inflator(base, september(y))
+		if r > 1.0 {
+			f *= r
+			base = september(y)
+		}
+	}
+	return f
+}
+
+// Adjustment is what the PIA is multiplied by for the claiming age.
+func (s *SocialSecurity) Adjustment() float64 {
+	birthDate := s.Sim().Actuary.BirthDate
+	fra := FullRetirementAge(birthDate)
+	months := int(math.Round(s.age * 12.0)) - int(math.Round(fra * 12.0))
+	return ClaimingAdjustment(months, DelayedRetirementCredit(birthDate))
+}
+
+// refigure works out the PIA and the worker's own benefit for a year.
+func (s *SocialSecurity) refigure(year int) {
+	if year == s.year {
+		return
+	}
+	s.year = year
+	s.pia = s.PIA(year)
+	if s.earnings == nil {
+		s.benefit = s.payout * s.cola(s.StartDate().Year(), year)
+	} else {
+		s.benefit = math.Floor(s.pia * s.Adjustment())
+	}
+}
+
+// claimed reports whether the worker's benefits have started by date.
+func (s *SocialSecurity) claimed(date time.Time) bool {
+	if s.earnings == nil {
+		return s.Age(date) >= s.age
+	}
+	return !date.Before(s.BenefitsDate())
+}
+
+// SpousalBenefit returns the benefit on the partner's record on top of
+// the worker's own, reduced for starting before full retirement age.
+func (s *SocialSecurity) SpousalBenefit(date time.Time) float64 {
+	p := s.partner
+	if p == nil || !s.claimed(date) || !p.claimed(date) || !date.Before(p.Sim().Actuary.DeathDate) {
+		return 0.0
+	}
+	s.refigure(date.Year())
+	p.refigure(date.Year())
+	excess := 0.5 * p.pia - s.pia
+	if excess <= 0.0 {
+		return 0.0
+	}
+	start := s.BenefitsDate()
+	if p.BenefitsDate().After(start) {
+		start = p.BenefitsDate()
+	}
+	birthDate := s.Sim().Actuary.BirthDate
+	fra := birthDate.AddDate(0, int(math.Round(FullRetirementAge(birthDate) * 12.0)), 0)
+	return math.Floor(excess * SpousalAdjustment(monthsBetween(fra, start)))
+}
+
+// deathBenefit returns what a worker who died on death would have been
+// paid in a year: their own benefit if they'd claimed, or else the PIA
+// with any delayed credits earned by then.
+func (s *SocialSecurity) deathBenefit(year int, death time.Time) float64 {
+	s.refigure(year)
+	if s.claimed(death) {
+		return s.benefit
+	}
+	birthDate := s.Sim().Actuary.BirthDate
+	fra := int(math.Round(FullRetirementAge(birthDate) * 12.0))
+	months := monthsBetween(birthDate, death) - fra
+	months = int(math.Max(0.0, math.Min(float64(months), LatestClaimingAge * 12.0 - float64(fra))))
+	return math.Floor(s.pia * ClaimingAdjustment(months, DelayedRetirementCredit(birthDate)))
+}
+
+// SurvivorBenefit returns the benefit on a late partner's record.  It's
+// reduced if it starts before the survivor's full retirement age, but
+// if the partner claimed early it's at least 82.5% of their PIA, up to
+// what the reduced PIA would be.
+func (s *SocialSecurity) SurvivorBenefit(date time.Time) float64 {
+	p := s.partner
+	if p == nil || !s.claimed(date) {
+		return 0.0
+	}
+	death := p.Sim().Actuary.DeathDate
+	if date.Before(death) {
+		return 0.0
+	}
+	base := p.deathBenefit(date.Year(), death)
+	start := s.BenefitsDate()
+	if death.After(start) {
+		start = death
+	}
+	birthDate := s.Sim().Actuary.BirthDate
+	fra := int(math.Round(SurvivorFullRetirementAge(birthDate) * 12.0))
+	adj := SurvivorAdjustment(monthsBetween(birthDate, start) - fra, fra)
+	if base < p.pia {
+		return math.Floor(math.Min(p.pia * adj, math.Max(base, 0.825 * p.pia)))
+	}
+	return math.Floor(base * adj)
+}
+
+// Earn returns the month's check: the worker's own benefit plus any
+// spousal benefit, or the survivor benefit if that's larger.
 func (s *SocialSecurity) Earn(date time.Time) float64 {
-	if s.Age(date) < s.age {
+	if !s.claimed(date) {
 		return 0.0
 	}
-	return s.payout
+	s.refigure(date.Year())
+	if s.partner != nil && !date.Before(s.partner.Sim().Actuary.DeathDate) {
+		return math.Max(s.benefit, s.SurvivorBenefit(date))
+	}
+	return s.benefit + s.SpousalBenefit(date)
 }
 
 func (s *SocialSecurity) Monthly(date time.Time) {
 	amount := s.Earn(date)
 	s.CashAccount().Deposit(amount, date, "Social Security")
-	s.TaxMen().Withhold(amount, date, false)
+	s.TaxMen().WithholdBenefits(amount, date)
 }
-
@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFullRetirementAge(t *testing.T) {
+	tests := []struct {
+		birthDate time.Time
+		want float64
+	}{
+		{testDate(1937, time.June, 1), 65.0},
+		{testDate(1938, time.January, 1), 65.0},
+		{testDate(1938, time.January, 2), 65.0 + 2.0 / 12.0},
+		{testDate(1942, time.December, 31), 65.0 + 10.0 / 12.0},
+		{testDate(1950, time.May, 5), 66.0},
+		{testDate(1955, time.March, 1), 66.0 + 2.0 / 12.0},
+		{testDate(1959, time.August, 1), 66.0 + 10.0 / 12.0},
+		{testDate(1960, time.January, 1), 66.0 + 10.0 / 12.0},
+		{testDate(1960, time.January, 2), 67.0},
+		{testDate(1980, time.January, 1), 67.0},
+	}
+	for _, tt := range tests {
+		got := FullRetirementAge(tt.birthDate)
+		if math.Abs(got - tt.want) > 1e-9 {
+			t.Errorf("FullRetirementAge(%s) = %v, want %v", tt.birthDate.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestClaimingAdjustment(t *testing.T) {
+	tests := []struct {
+		months int
+		credit float64
+		want float64
+	}{
+		{-60, 0.08, 0.70},
+		{-48, 0.08, 0.75},
+		{-36, 0.08, 0.80},
+		{-12, 0.08, 1.0 - 12.0 * 5.0 / 900.0},
+		{0, 0.08, 1.0},
+		{12, 0.08, 1.08},
+		{36, 0.08, 1.24},
+		{24, 0.065, 1.13},
+	}
+	for _, tt := range tests {
+		got := ClaimingAdjustment(tt.months, tt.credit)
+		if math.Abs(got - tt.want) > 1e-9 {
+			t.Errorf("ClaimingAdjustment(%d, %v) = %v, want %v", tt.months, tt.credit, got, tt.want)
+		}
+	}
+}
+
+// TestBendPoints checks the bend points against the ones the SSA
+// published for workers who turned 62 in each year.
+func TestBendPoints(t *testing.T) {
+	tests := []struct {
+		birthDate string
+		bp1 float64
+		bp2 float64
+	}{
+		{"1961-06-01", 1115, 6721},
+		{"1962-06-01", 1174, 7078},
+		{"1963-06-01", 1226, 7391},
+		{"1964-06-01", 1286, 7749},
+	}
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.BirthDate = tt.birthDate
+		s := testSimulation(t, config)
+		bp1, bp2 := s.SocialSecurity.BendPoints()
+		if bp1 != tt.bp1 || bp2 != tt.bp2 {
+			t.Errorf("born %s: bend points %v, %v, want %v, %v", tt.birthDate, bp1, bp2, tt.bp1, tt.bp2)
+		}
+	}
+}
+
+// TestTaxableMaximum checks the published wage bases and that later
+// ones grow from them in $300 steps.
+func TestTaxableMaximum(t *testing.T) {
+	s := testSimulation(t, testConfig(t))
+	tests := []struct {
+		year int
+		want float64
+	}{
+		{2024, 168600},
+		{2025, 176100},
+		{2026, 184500},
+	}
+	for _, tt := range tests {
+		got := s.SocialSecurity.TaxableMaximum(tt.year)
+		if got != tt.want {
+			t.Errorf("TaxableMaximum(%d) = %v, want %v", tt.year, got, tt.want)
+		}
+	}
+	last := s.SocialSecurity.TaxableMaximum(2026)
+	for year := 2027; year < 2040; year++ {
+		got := s.SocialSecurity.TaxableMaximum(year)
+		if got < last || math.Mod(got, 300.0) != 0.0 {
+			t.Errorf("TaxableMaximum(%d) = %v after %v", year, got, last)
+		}
+		last = got
+	}
+}
@@ -0,0 +1,209 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	SpendFixed = "fixed"
+	SpendConstant = "constant"
+	SpendFourPercent = "four_percent"
+	SpendGuardrails = "guardrails"
+	SpendVPW = "vpw"
+	SpendFloorCeiling = "floor_ceiling"
+)
+
+// SpendingPolicies lists the policies spending.policy can name.
+var SpendingPolicies = []string{
+	SpendFixed,
+	SpendConstant,
+	SpendFourPercent,
+	SpendGuardrails,
+	SpendVPW,
+	SpendFloorCeiling,
+}
+
+// SpendingPolicy decides how much the household lives on each month.
+type SpendingPolicy interface {
+	Monthly(date time.Time) float64
+}
+
+// SpendingRule sets the year's spending, given last year's.  last is 0
+// the first time it's called, at retirement.
+type SpendingRule func(date time.Time, last float64) float64
+
+func NewSpendingPolicy(sim *Simulation, monthly float64, config *SpendingConfig) SpendingPolicy {
+	if config == nil || config.Policy == "" || config.Policy == SpendFixed {
+		return &FixedSpending{monthly: monthly}
+	}
+	rate := config.Rate
+	var rule SpendingRule
+	switch config.Policy {
+	case SpendConstant:
+		rule = ConstantSpending(sim, monthly)
+	case SpendFourPercent:
+		if rate == 0.0 {
+			rate = 0.04
+		}
+		rule = FourPercentSpending(sim, rate)
+	case SpendGuardrails:
+		if rate == 0.0 {
+			rate = 0.05
+		}
+		rule = GuardrailSpending(sim, rate)
+	case SpendVPW:
+		if rate == 0.0 {
+			rate = 0.04
+		}
+		rule = VPWSpending(sim, rate)
+	case SpendFloorCeiling:
+		if rate == 0.0 {
+			rate = 0.04
+		}
+		floor, ceiling := config.Floor, config.Ceiling
+		if floor == 0.0 {
+			floor = 0.85
+		}
+		if ceiling == 0.0 {
+			ceiling = 1.15
+		}
+		rule = FloorCeilingSpending(sim, rate, floor, ceiling)
+	}
+	return &RetirementSpending{
+		Simulacrum: NewSimulacrum(sim),
+		monthly: monthly,
+		rule: rule,
+	}
+}
+
+// FixedSpending spends the same nominal amount every month, forever.
+type FixedSpending struct {
+	monthly float64
+}
+
+func (p *FixedSpending) Monthly(date time.Time) float64 {
+	return p.monthly
+}
+
+// RetirementSpending spends monthly_living, kept up with inflation,
+// until retirement.  From then on its rule sets a budget at retirement
+// and on each anniversary of it.
+type RetirementSpending struct {
+	*Simulacrum
+	monthly float64
+	rule SpendingRule
+	annual float64
+	next time.Time
+}
+
+func (p *RetirementSpending) Monthly(date time.Time) float64 {
+	if date.Before(p.Sim().RetirementDate()) {
+		return p.monthly * p.Sim().Economy.Inflator(p.StartDate(), date)
+	}
+	if p.next.IsZero() || !date.Before(p.next) {
+		p.annual = math.Max(0.0, p.rule(date, p.annual))
+		p.next = date.AddDate(1, 0, 0)
+	}
+	return p.annual / 12.0
+}
+
+// InvestedBalance is the value of every investment account.
+func (s *Simulation) InvestedBalance() float64 {
+	var balance float64 = 0.0
+	for _, acct := range s.Investments {
+		balance += acct.Balance()
+	}
+	return balance
+}
+
+func lastYearsInflation(sim *Simulation, date time.Time) float64 {
+	return sim.Economy.Inflator(date.AddDate(-1, 0, 0), date)
+}
+
+// ConstantSpending keeps spending monthly_living in real terms through
+// retirement.
+func ConstantSpending(sim *Simulation, monthly float64) SpendingRule {
+	return func(date time.Time, last float64) float64 {
+		return monthly * 12.0 * sim.Economy.Inflator(sim.StartDate(), date)
+	}
+}
+
+// FourPercentSpending spends rate of the portfolio in the first year of
+// retirement and keeps that amount up with inflation after.
+func FourPercentSpending(sim *Simulation, rate float64) SpendingRule {
+	return func(date time.Time, last float64) float64 {
+		if last == 0.0 {
+			return rate * sim.InvestedBalance()
+		}
+		return last * lastYearsInflation(sim, date)
+	}
+}
+
+// GuardrailSpending follows the Guyton-Klinger rules.  Spending starts
+// at rate of the portfolio and keeps up with inflation, except after a
+// year the portfolio lost money while the withdrawal rate was above
+// where it started.  Whether it lost money is figured from what it's
+// worth now, before the year's withdrawals and deposits.  If the
+// withdrawal rate drifts 20% above the initial rate spending is cut
+// 10%, and if it drifts 20% below it's raised 10%.
+func GuardrailSpending(sim *Simulation, rate float64) SpendingRule {
+	var lastBalance float64
+	var lastDate time.Time
+	return func(date time.Time, last float64) float64 {
+		balance := sim.InvestedBalance()
+		var flows float64 = 0.0
+		for _, acct := range sim.Investments {
+			flows += acct.Flows(lastDate, date)
+		}
+		lost := balance - flows < lastBalance
+		lastBalance = balance
+		lastDate = date
+		if last == 0.0 {
+			return rate * balance
+		}
+		if balance <= 0.0 {
+			return 0.0
+		}
+		spend := last
+		if !lost || spend / balance <= rate {
+			spend *= lastYearsInflation(sim, date)
+		}
+		wr := spend / balance
+		if wr > rate * 1.2 {
+			spend *= 0.9
+		} else if wr < rate * 0.8 {
+			spend *= 1.1
+		}
+		return spend
+	}
+}
+
+// VPWSpending is variable percentage withdrawal: each year the
+// portfolio is spent down as an annuity paid out through age 100,
+// assuming it earns rate after inflation.
+func VPWSpending(sim *Simulation, rate float64) SpendingRule {
+	return func(date time.Time, last float64) float64 {
+		years := math.Max(1.0, math.Floor(100.0 - sim.Actuary.Age(date)))
+		balance := sim.InvestedBalance()
+		return balance * rate / (1.0 - math.Pow(1.0 + rate, -years)) / (1.0 + rate)
+	}
+}
+
+// FloorCeilingSpending spends rate of the portfolio each year, but never
+// less than floor or more than ceiling times the first year's spending,
+// adjusted for inflation.
+func FloorCeilingSpending(sim *Simulation, rate, floor, ceiling float64) SpendingRule {
+	var initial float64
+	var retired time.Time
+	return func(date time.Time, last float64) float64 {
+		spend := rate * sim.InvestedBalance()
+		if last == 0.0 {
+			initial = spend
+			retired = date
+			return spend
+		}
+		base := initial * sim.Economy.Inflator(retired, date)
+		return math.Min(ceiling * base, math.Max(floor * base, spend))
+	}
+}
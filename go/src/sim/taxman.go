@@ -1,59 +1,186 @@
 package sim
 
 import (
+	_ "embed"
+	"encoding/json"
+	"io/ioutil"
 	"math"
+	"sort"
 	"time"
 )
 
 type Bracket struct {
-	Min float64
-	Max float64
-	Rate float64
-}
-
-var Brackets = map[string][]*Bracket {
-	"US": []*Bracket{
-		&Bracket{0.0, 9525.0, 0.1},
-		&Bracket{9525.0, 38700.0, 0.15},
-		&Bracket{38700.0, 93700.0, 0.25},
-		&Bracket{93700.0, 195450.0, 0.28},
-		&Bracket{195450.0, 424950.0, 0.33},
-		&Bracket{424950.0, 426700.0, 0.35},
-		&Bracket{426700.0, math.MaxFloat64, 0.396},
-	},
-	"SSA": []*Bracket{
-		&Bracket{0.0, 128400.0, 0.062},
-		&Bracket{128400.0, math.MaxFloat64, 0.0},
-	},
-	"MED": []*Bracket{
-		&Bracket{0.0, math.MaxFloat64, 0.0145},
-	},
-	"CA": []*Bracket{
-		&Bracket{0.0, 8223.0, 0.01},
-		&Bracket{8223.0, 19495.0, 0.02},
-		&Bracket{19495.0, 30769.0, 0.04},
-		&Bracket{30769.0, 42711.0, 0.06},
-		&Bracket{42711.0, 53980.0, 0.08},
-		&Bracket{53980.0, 275738.0, 0.093},
-		&Bracket{275738.0, 330884.0, 0.103},
-		&Bracket{330884.0, 551473.0, 0.113},
-		&Bracket{551473.0, math.MaxFloat64, 0.123},
-	},
-}
-
-var StandardDeduction = map[string]float64{
-	"US": 6500.0,
-	"SSA": 0.0,
-	"MED": 0.0,
-	"CA": 4236.0,
+	Min float64 `json:"min"`
+	Max float64 `json:"-"`
+	Rate float64 `json:"rate"`
+}
+
+const (
+	FilingSingle = "single"
+	FilingJoint = "joint"
+	FilingWidow = "widow"
+)
+
+// MaxCapitalLoss is how much of a net capital loss can be deducted
+// from ordinary income each year.  The rest carries over.
+const MaxCapitalLoss = 3000.0
+
+// EarlyWithdrawalRate is the additional tax on retirement account
+// withdrawals taken before 59½.
+const EarlyWithdrawalRate = 0.10
+
+// MaxTaxableBenefits is the most of a year's Social Security benefits
+// that can be taxable.
+const MaxTaxableBenefits = 0.85
+
+// ProvisionalIncomeThresholds returns the provisional income above which
+// half, and then 85%, of Social Security benefits are taxable.  Unlike
+// the rest of the tax code they aren't indexed to inflation.  Qualifying
+// widow(er)s use the single thresholds.
+func ProvisionalIncomeThresholds(status string) (base, adjusted float64) {
+	if status == FilingJoint {
+		return 32000.0, 44000.0
+	}
+	return 25000.0, 34000.0
+}
+
+// TaxTable is one year's tax schedule for a jurisdiction.  Capital
+// gains and qualified dividends are taxed on the CapitalGains schedule,
+// stacked on top of ordinary income; jurisdictions without one tax them
+// as ordinary income.  Social Security benefits are taxed the federal
+// way unless the jurisdiction exempts them.
+type TaxTable struct {
+	SocialSecurityExempt bool `json:"social_security_exempt"`
+	StandardDeduction float64 `json:"standard_deduction"`
+	Brackets []*Bracket `json:"brackets"`
+	CapitalGains []*Bracket `json:"capital_gains"`
+	Limits map[string]float64 `json:"limits"`
+	Joint *TaxTable `json:"joint"`
+}
+
+// finishBrackets sorts the brackets and fills in where each one ends.
+func finishBrackets(bs []*Bracket) {
+	sort.Slice(bs, func(i, j int) bool {
+		return bs[i].Min < bs[j].Min
+	})
+	for i, b := range bs {
+		if i + 1 < len(bs) {
+			b.Max = bs[i+1].Min
+		} else {
+			b.Max = math.MaxFloat64
+		}
+	}
+}
+
+func indexBrackets(bs []*Bracket, f float64) []*Bracket {
+	if bs == nil {
+		return nil
+	}
+	out := make([]*Bracket, len(bs))
+	for i, b := range bs {
+		max := b.Max
+		if max != math.MaxFloat64 {
+			max *= f
+		}
+		out[i] = &Bracket{b.Min * f, max, b.Rate}
+	}
+	return out
+}
+
+func (tt *TaxTable) finish() {
+	finishBrackets(tt.Brackets)
+	finishBrackets(tt.CapitalGains)
+	if tt.Joint != nil {
+		tt.Joint.finish()
+	}
+}
+
+// Filing returns the table for a filing status.  Qualifying widow(er)s
+// use the joint table, and jurisdictions without a joint table tax
+// everyone the same.
+func (tt *TaxTable) Filing(status string) *TaxTable {
+	if status != FilingSingle && tt.Joint != nil {
+		return tt.Joint
+	}
+	return tt
+}
+
+// indexed returns a copy of the table with its bracket edges,
+// deduction and contribution limits scaled by f.
+func (tt *TaxTable) indexed(f float64) *TaxTable {
+	out := &TaxTable{
+		SocialSecurityExempt: tt.SocialSecurityExempt,
+		StandardDeduction: tt.StandardDeduction * f,
+		Brackets: indexBrackets(tt.Brackets, f),
+		CapitalGains: indexBrackets(tt.CapitalGains, f),
+	}
+	if tt.Limits != nil {
+		out.Limits = map[string]float64{}
+		for k, v := range tt.Limits {
+			out.Limits[k] = v * f
+		}
+	}
+	if tt.Joint != nil {
+		out.Joint = tt.Joint.indexed(f)
+	}
+	return out
+}
+
+// TaxTables holds the published tax tables for each jurisdiction, by
+// year.  The defaults are loaded from taxes.json; LoadTaxTables can add
+// more years or jurisdictions before any simulations start.
+var TaxTables = map[string]map[int]*TaxTable{}
+
+//go:embed taxes.json
+var defaultTaxTables []byte
+
+func init() {
+	err := ParseTaxTables(defaultTaxTables)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func LoadTaxTables(fn string) error {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	return ParseTaxTables(data)
+}
+
+// ParseTaxTables merges a JSON document of tax tables, keyed by
+// jurisdiction and then year, into TaxTables.  Brackets only list their
+// lower edge; each bracket runs up to the start of the next one.
+func ParseTaxTables(data []byte) error {
+	tables := map[string]map[int]*TaxTable{}
+	err := json.Unmarshal(data, &tables)
+	if err != nil {
+		return err
+	}
+	for name, years := range tables {
+		if TaxTables[name] == nil {
+			TaxTables[name] = map[int]*TaxTable{}
+		}
+		for year, tt := range years {
+			tt.finish()
+			TaxTables[name][year] = tt
+		}
+	}
+	return nil
 }
 
 type TaxMan struct {
 	*Simulacrum
 	state string
 	earnings []float64
+	benefits []float64
 	withholding []float64
 	deductions []float64
+	gains []float64
+	lossCarryover float64
+	tables map[int]*TaxTable
+	wageBase func(year int) float64
 }
 
 func NewTaxMan(sim *Simulation, state string) *TaxMan {
@@ -61,62 +188,199 @@ func NewTaxMan(sim *Simulation, state string) *TaxMan {
 		Simulacrum: NewSimulacrum(sim),
 		state: state,
 		earnings: []float64{},
+		benefits: []float64{},
 		withholding: []float64{},
 		deductions: []float64{},
+		gains: []float64{},
+		tables: map[int]*TaxTable{},
+	}
+}
+
+// NewSocialSecurityTaxMan taxes wages up to the same taxable maximum
+// the worker's benefits are figured on.
+func NewSocialSecurityTaxMan(sim *Simulation) *TaxMan {
+	t := NewTaxMan(sim, "SSA")
+	t.wageBase = func(year int) float64 {
+		return sim.SocialSecurity.TaxableMaximum(year)
 	}
+	return t
 }
 
 func (t *TaxMan) Name() string {
 	return t.state
 }
 
-func (t *TaxMan) StandardDeduction() float64 {
-	return StandardDeduction[t.state]
+// Published returns the tax table for a year.  Years before the first
+// published table use the first table; years after the last one use
+// the last table indexed to inflation since then.
+func (t *TaxMan) Published(year int) *TaxTable {
+	tt, ok := t.tables[year]
+	if ok {
+		return tt
+	}
+	published := TaxTables[t.state]
+	first, last := 0, 0
+	for y := range published {
+		if first == 0 || y < first {
+			first = y
+		}
+		if y > last {
+			last = y
+		}
+	}
+	if len(published) == 0 {
+		tt = &TaxTable{}
+	} else if year < first {
+		tt = published[first]
+	} else if year > last {
+		from := time.Date(last, time.January, 1, 0, 0, 0, 0, time.Local)
+		to := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		tt = published[last].indexed(t.Sim().Economy.Inflator(from, to))
+	} else {
+		for y := year; tt == nil; y-- {
+			tt = published[y]
+		}
+	}
+	t.tables[year] = tt
+	return tt
+}
+
+// Table returns the tax table for a year and the household's filing
+// status that year.
+func (t *TaxMan) Table(year int) *TaxTable {
+	return t.Published(year).Filing(t.Sim().TaxMen.FilingStatus(year))
+}
+
+// Limit returns one of the year's contribution limits, or 0 if the
+// jurisdiction doesn't publish it.
+func (t *TaxMan) Limit(name string, year int) float64 {
+	return t.Published(year).Limits[name]
+}
+
+func (t *TaxMan) StandardDeduction(year int) float64 {
+	return t.Table(year).StandardDeduction
 }
 
-func (t *TaxMan) Brackets() []*Bracket {
-	return Brackets[t.state]
+func (t *TaxMan) Brackets(year int) []*Bracket {
+	return t.Table(year).Brackets
 }
 
 func (t *TaxMan) Withhold(earnings float64, date time.Time) float64 {
 	t.earnings = append(t.earnings, earnings)
-	w := t.Bracket(earnings * 12.0 - t.StandardDeduction()) / 12.0
+	year := date.Year()
+	annual := earnings * 12.0
+	if t.wageBase != nil {
+		annual = math.Min(annual, t.wageBase(year))
+	}
+	w := t.Bracket(annual - t.StandardDeduction(year), year) / 12.0
 	t.CashAccount().Withdraw(w, date, t.Name() + " Withholding")
 	t.withholding = append(t.withholding, w)
 	return w
 }
 
+// WithholdBenefits records Social Security benefits, whose taxable part
+// is settled with the annual return, and withholds as if the most that
+// can be taxable is.
+func (t *TaxMan) WithholdBenefits(benefits float64, date time.Time) float64 {
+	t.benefits = append(t.benefits, benefits)
+	year := date.Year()
+	if t.Published(year).SocialSecurityExempt {
+		return 0.0
+	}
+	w := t.Bracket(benefits * MaxTaxableBenefits * 12.0 - t.StandardDeduction(year), year) / 12.0
+	t.CashAccount().Withdraw(w, date, t.Name() + " Withholding")
+	t.withholding = append(t.withholding, w)
+	return w
+}
+
+// TaxableBenefits returns how much of the year's Social Security
+// benefits are taxable, given the rest of the year's income.  Nothing is
+// until provisional income, that income plus half the benefits, passes
+// the base threshold; then half of each dollar over it is, up to half
+// the benefits; past the adjusted threshold 85% of each dollar over is,
+// up to 85% of the benefits.
+func (t *TaxMan) TaxableBenefits(income float64, year int) float64 {
+	var benefits float64 = 0.0
+	for _, b := range t.benefits {
+		benefits += b
+	}
+	if benefits <= 0.0 || t.Published(year).SocialSecurityExempt {
+		return 0.0
+	}
+	base, adjusted := ProvisionalIncomeThresholds(t.Sim().TaxMen.FilingStatus(year))
+	provisional := income + 0.5 * benefits
+	if provisional <= base {
+		return 0.0
+	}
+	if provisional <= adjusted {
+		return math.Min(0.5 * benefits, 0.5 * (provisional - base))
+	}
+	taxable := MaxTaxableBenefits * (provisional - adjusted) + math.Min(0.5 * benefits, 0.5 * (adjusted - base))
+	return math.Min(MaxTaxableBenefits * benefits, taxable)
+}
+
 func (t *TaxMan) Deduct(amount float64) {
 	t.deductions = append(t.deductions, amount)
 }
 
-func (t *TaxMan) Tax() (total, owed float64) {
+// CapitalGain records a realized gain (or loss, if negative) or a
+// qualified dividend.  Nothing is withheld; it's settled with the
+// annual return.
+func (t *TaxMan) CapitalGain(amount float64) {
+	t.gains = append(t.gains, amount)
+}
+
+func (t *TaxMan) Tax(year int) (total, owed float64) {
 	var paid float64 = 0.0
 	for _, w := range t.withholding {
 		paid += w
 	}
-	var net float64 = 0.0
+	var income float64 = 0.0
 	for _, e := range t.earnings {
-		net += e
+		income += e
 	}
+	net := income
 	for _, d := range t.deductions {
 		net -= d
 	}
-	net -= t.StandardDeduction()
+	gains := -1.0 * t.lossCarryover
+	for _, g := range t.gains {
+		gains += g
+	}
+	t.lossCarryover = 0.0
+	if gains < 0.0 {
+		loss := math.Min(-1.0 * gains, MaxCapitalLoss)
+		net -= loss
+		income -= loss
+		t.lossCarryover = -1.0 * gains - loss
+		gains = 0.0
+	}
+	// deductions aren't told apart from itemized ones, so provisional
+	// income leaves them all out
+	net += t.TaxableBenefits(income + gains, year)
+	if t.Table(year).CapitalGains == nil {
+		net += gains
+		gains = 0.0
+	}
+	net -= t.StandardDeduction(year)
 	if net < 0.0 {
-		return 0.0, -1.0 * paid
+		// unused deductions offset gains
+		gains = math.Max(0.0, gains + net)
+		net = 0.0
 	}
-	total = t.Bracket(net)
+	total = t.Bracket(net, year) + t.CapitalGainsBracket(net, gains, year)
 	owed = total - paid
 	t.earnings = []float64{}
+	t.benefits = []float64{}
 	t.withholding = []float64{}
 	t.deductions = []float64{}
+	t.gains = []float64{}
 	return total, owed
 }
 
-func (t *TaxMan) Bracket(net float64) float64 {
+func (t *TaxMan) Bracket(net float64, year int) float64 {
 	var tax float64 = 0.0
-	for _, b := range t.Brackets() {
+	for _, b := range t.Brackets(year) {
 		if net < b.Min {
 			break
 		}
@@ -125,24 +389,96 @@ func (t *TaxMan) Bracket(net float64) float64 {
 	return tax
 }
 
+// CapitalGainsBracket returns the tax on gains stacked on top of net
+// ordinary income.
+func (t *TaxMan) CapitalGainsBracket(net, gains float64, year int) float64 {
+	var tax float64 = 0.0
+	for _, b := range t.Table(year).CapitalGains {
+		lo := math.Max(b.Min, net)
+		hi := math.Min(b.Max, net + gains)
+		if hi > lo {
+			tax += b.Rate * (hi - lo)
+		}
+	}
+	return tax
+}
+
+// BracketRoom returns how much more ordinary income the year can take,
+// on top of what has been earned so far, before any of it is taxed
+// above rate.
+func (t *TaxMan) BracketRoom(rate float64, year int) float64 {
+	var top float64 = 0.0
+	for _, b := range t.Brackets(year) {
+		if b.Rate <= rate {
+			top = b.Max
+		}
+	}
+	var earned float64 = 0.0
+	for _, e := range t.earnings {
+		earned += e
+	}
+	income := earned + t.TaxableBenefits(earned, year) - t.StandardDeduction(year)
+	for _, d := range t.deductions {
+		income -= d
+	}
+	return math.Max(0.0, top - math.Max(0.0, income))
+}
+
 type TaxMen struct {
 	*Simulacrum
 	Federal *TaxMan
 	SocialSecurity *TaxMan
 	Medicare *TaxMan
 	State *TaxMan
+	household *TaxMen
 }
 
 func NewTaxMen(sim *Simulation, state string) *TaxMen {
 	return &TaxMen{
 		Simulacrum: NewSimulacrum(sim),
 		Federal: NewTaxMan(sim, "US"),
-		SocialSecurity: NewTaxMan(sim, "SSA"),
+		SocialSecurity: NewSocialSecurityTaxMan(sim),
 		Medicare: NewTaxMan(sim, "MED"),
 		State: NewTaxMan(sim, state),
 	}
 }
 
+// NewJointTaxMen returns the tax collectors for a spouse.  Payroll
+// taxes are still levied on each spouse separately, but income taxes
+// are pooled on the household's joint return.
+func NewJointTaxMen(sim *Simulation, household *TaxMen) *TaxMen {
+	return &TaxMen{
+		Simulacrum: NewSimulacrum(sim),
+		Federal: household.Federal,
+		SocialSecurity: NewSocialSecurityTaxMan(sim),
+		Medicare: NewTaxMan(sim, "MED"),
+		State: household.State,
+		household: household,
+	}
+}
+
+// FilingStatus returns the household's filing status for a year.  A
+// couple files jointly through the year the spouse dies, the survivor
+// files as a qualifying widow(er) for the two years after that, and as
+// single from then on.
+func (t *TaxMen) FilingStatus(year int) string {
+	if t.household != nil {
+		return t.household.FilingStatus(year)
+	}
+	spouse := t.Sim().Spouse
+	if spouse == nil {
+		return FilingSingle
+	}
+	died := spouse.Actuary.DeathDate.Year()
+	if year <= died {
+		return FilingJoint
+	}
+	if year <= died + 2 {
+		return FilingWidow
+	}
+	return FilingSingle
+}
+
 func (t *TaxMen) Withhold(earnings float64, date time.Time, payroll bool) float64 {
 	if earnings <= 0.0 {
 		return 0.0
@@ -157,15 +493,62 @@ func (t *TaxMen) Withhold(earnings float64, date time.Time, payroll bool) float6
 	return w
 }
 
+// WithholdWages withholds payroll taxes on a whole paycheck, but income
+// taxes only on the part that wasn't deferred pre-tax into a 401k.
+func (t *TaxMen) WithholdWages(wages, pretax float64, date time.Time) float64 {
+	if wages <= 0.0 {
+		return 0.0
+	}
+	var w float64 = 0.0
+	w += t.SocialSecurity.Withhold(wages, date)
+	w += t.Medicare.Withhold(wages, date)
+	w += t.Withhold(wages - pretax, date, false)
+	return w
+}
+
+// WithholdBenefits withholds income taxes on Social Security benefits.
+// There are no payroll taxes on them.
+func (t *TaxMen) WithholdBenefits(benefits float64, date time.Time) float64 {
+	if benefits <= 0.0 {
+		return 0.0
+	}
+	var w float64 = 0.0
+	w += t.Federal.WithholdBenefits(benefits, date)
+	w += t.State.WithholdBenefits(benefits, date)
+	return w
+}
+
+// EarlyWithdrawal pays the additional tax on the penalized part of an
+// early withdrawal.
+func (t *TaxMen) EarlyWithdrawal(amount float64, date time.Time) float64 {
+	if amount <= 0.0 {
+		return 0.0
+	}
+	p := amount * EarlyWithdrawalRate
+	t.CashAccount().Withdraw(p, date, EarlyWithdrawalPenalty)
+	return p
+}
+
 func (t *TaxMen) Deduct(amount float64) {
 	t.Federal.Deduct(amount)
 	t.State.Deduct(amount)
 }
 
+func (t *TaxMen) CapitalGain(amount float64) {
+	t.Federal.CapitalGain(amount)
+	t.State.CapitalGain(amount)
+}
+
 func (t *TaxMen) Annual(date time.Time) {
-	stTot, stOwed := t.State.Tax()
+	if t.household != nil {
+		// settled on the household's return
+		return
+	}
+	// taxes settled in January are for the year just ended
+	year := date.Year() - 1
+	stTot, stOwed := t.State.Tax(year)
 	t.Federal.Deduct(stTot)
-	_, fedOwed := t.Federal.Tax()
+	_, fedOwed := t.Federal.Tax(year)
 	owed := stOwed + fedOwed
 	if owed > 0.0 {
 		t.CashAccount().Withdraw(owed, date, TaxPayment)
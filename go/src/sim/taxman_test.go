@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBracket(t *testing.T) {
+	tests := []struct {
+		joint bool
+		net float64
+		want float64
+	}{
+		{false, 0, 0},
+		{false, 10000, 1000},
+		{false, 47150, 1160 + 4266},
+		{false, 100000, 1160 + 4266 + 11627},
+		{true, 23200, 2320},
+		{true, 100000, 2320 + 8532 + 1254},
+	}
+	for _, tt := range tests {
+		config := testConfig(t)
+		if !tt.joint {
+			config.Spouse = nil
+		}
+		s := testSimulation(t, config)
+		got := s.TaxMen.Federal.Bracket(tt.net, 2024)
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("joint %v: Bracket(%v) = %v, want %v", tt.joint, tt.net, got, tt.want)
+		}
+	}
+}
+
+func TestCapitalGainsBracket(t *testing.T) {
+	s := testSimulation(t, testConfig(t))
+	tests := []struct {
+		net float64
+		gains float64
+		want float64
+	}{
+		{50000, 40000, 0},
+		{80000, 30000, 0.15 * (110000 - 94050)},
+		{100000, 30000, 0.15 * 30000},
+		{570000, 30000, 0.15 * (583750 - 570000) + 0.20 * (600000 - 583750)},
+	}
+	for _, tt := range tests {
+		got := s.TaxMen.Federal.CapitalGainsBracket(tt.net, tt.gains, 2024)
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("CapitalGainsBracket(%v, %v) = %v, want %v", tt.net, tt.gains, got, tt.want)
+		}
+	}
+}
+
+func TestTaxableBenefits(t *testing.T) {
+	tests := []struct {
+		joint bool
+		income float64
+		benefits float64
+		want float64
+	}{
+		{false, 10000, 20000, 0},
+		{false, 20000, 20000, 2500},
+		{false, 28000, 20000, 0.85 * 4000 + 4500},
+		{false, 40000, 20000, 17000},
+		{true, 10000, 20000, 0},
+		{true, 30000, 20000, 4000},
+		{true, 40000, 20000, 0.85 * 6000 + 6000},
+		{true, 60000, 20000, 17000},
+	}
+	for _, tt := range tests {
+		config := testConfig(t)
+		if !tt.joint {
+			config.Spouse = nil
+		}
+		s := testSimulation(t, config)
+		tm := NewTaxMan(s, "US")
+		tm.benefits = []float64{tt.benefits}
+		got := tm.TaxableBenefits(tt.income, 2024)
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("joint %v: TaxableBenefits(%v) of %v = %v, want %v", tt.joint, tt.income, tt.benefits, got, tt.want)
+		}
+	}
+}
+
+// TestSocialSecurityWageBase checks that payroll withholding stops at
+// the taxable maximum.
+func TestSocialSecurityWageBase(t *testing.T) {
+	s := testSimulation(t, testConfig(t))
+	tests := []struct {
+		monthly float64
+		want float64
+	}{
+		{5000, 0.062 * 5000},
+		{50000, 0.062 * 176100 / 12.0},
+	}
+	for _, tt := range tests {
+		got := s.TaxMen.SocialSecurity.Withhold(tt.monthly, testDate(2025, time.March, 1))
+		if math.Abs(got - tt.want) > 0.005 {
+			t.Errorf("Withhold(%v) = %v, want %v", tt.monthly, got, tt.want)
+		}
+	}
+}
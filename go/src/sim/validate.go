@@ -0,0 +1,643 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ConfigError struct {
+	Path string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *ConfigErrors) add(path, format string, args ...interface{}) {
+	*e = append(*e, &ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// date parses an optional date, recording an error if it's present but
+// unparseable.  ok is false unless a valid date was found.
+func (e *ConfigErrors) date(path, value string, required bool) (date time.Time, ok bool) {
+	if value == "" {
+		if required {
+			e.add(path, "missing date")
+		}
+		return date, false
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		e.add(path, "invalid date %q, expected YYYY-MM-DD", value)
+		return date, false
+	}
+	return date, true
+}
+
+func (e *ConfigErrors) balance(path string, value float64) {
+	if value < 0.0 {
+		e.add(path, "balance %.2f is negative", value)
+	}
+}
+
+func (e *ConfigErrors) dueDate(path, value string, required bool, start time.Time) {
+	due, ok := e.date(path, value, required)
+	if ok && due.Before(start) {
+		e.add(path, "due date %s is before the simulation start %s", value, start.Format("2006-01-02"))
+	}
+}
+
+func sortedKeys(v interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(v).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var simConfigType = reflect.TypeOf(&SimConfig{})
+
+// unknownFields walks decoded JSON alongside the type it was decoded
+// into and returns the path of every key that the type doesn't have.
+// Nested SimConfigs collect their own unknown fields when they are
+// decoded, so they're skipped here.
+func unknownFields(path string, raw interface{}, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	out := []string{}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return out
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[strings.ToLower(name)] = f.Type
+		}
+		for _, k := range sortedKeys(obj) {
+			ft, ok := fields[strings.ToLower(k)]
+			if !ok {
+				out = append(out, joinPath(path, k))
+			} else if ft != simConfigType {
+				out = append(out, unknownFields(joinPath(path, k), obj[k], ft)...)
+			}
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return out
+		}
+		for _, k := range sortedKeys(obj) {
+			out = append(out, unknownFields(joinPath(path, k), obj[k], t.Elem())...)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := raw.([]interface{})
+		if !ok {
+			return out
+		}
+		for i, v := range arr {
+			out = append(out, unknownFields(path + "[" + strconv.Itoa(i) + "]", v, t.Elem())...)
+		}
+	}
+	return out
+}
+
+func (c *SimConfig) UnmarshalJSON(data []byte) error {
+	type plainConfig SimConfig
+	err := json.Unmarshal(data, (*plainConfig)(c))
+	if err != nil {
+		return err
+	}
+	var raw interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	c.unknown = unknownFields("", raw, reflect.TypeOf(c))
+	return nil
+}
+
+// Validate checks the config for everything that would otherwise be
+// silently patched over or cause a panic once a simulation starts.  It
+// returns ConfigErrors listing every problem found.
+func (c *SimConfig) Validate() error {
+	errs := ConfigErrors{}
+	start := c.Start()
+	if c.StartDate != "" {
+		d, ok := errs.date("start_date", c.StartDate, false)
+		if ok {
+			start = startOfMonth(d)
+		}
+	}
+	c.validate(&errs, "", start, true)
+	if c.Spouse != nil {
+		c.Spouse.validate(&errs, "spouse", start, false)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c *SimConfig) validate(errs *ConfigErrors, path string, start time.Time, primary bool) {
+	for _, k := range c.unknown {
+		errs.add(joinPath(path, k), "unknown field")
+	}
+	birthDate, ok := errs.date(joinPath(path, "birth_date"), c.BirthDate, true)
+	if ok {
+		age := years(start.Sub(birthDate))
+		if c.RetirementAge < age {
+			errs.add(joinPath(path, "retirement_age"), "retirement age %g is below the current age %.1f", c.RetirementAge, age)
+		}
+	}
+	c.validateSocialSecurity(errs, path, start)
+	if primary && c.RiskProfile == nil {
+		errs.add(joinPath(path, "risk_profile"), "missing risk profile")
+	} else if c.RiskProfile != nil {
+		c.RiskProfile.validate(errs, joinPath(path, "risk_profile"))
+	}
+	if c.Economy != nil {
+		if !primary {
+			errs.add(joinPath(path, "economy"), "the economy is set for the whole household")
+		} else {
+			c.Economy.validate(errs, joinPath(path, "economy"))
+		}
+	}
+	if c.Spending != nil {
+		if !primary {
+			errs.add(joinPath(path, "spending"), "spending is set for the whole household")
+		} else {
+			c.Spending.validate(errs, joinPath(path, "spending"))
+		}
+	}
+	if c.WithdrawalStrategy != "" {
+		if !primary {
+			errs.add(joinPath(path, "withdrawal_strategy"), "the withdrawal strategy is set for the whole household")
+		} else if !contains(WithdrawalStrategies, c.WithdrawalStrategy) {
+			errs.add(joinPath(path, "withdrawal_strategy"), "unknown withdrawal strategy %q, expected one of %s", c.WithdrawalStrategy, strings.Join(WithdrawalStrategies, ", "))
+		}
+	}
+	for _, name := range sortedKeys(c.Children) {
+		p := joinPath(path, "children." + name)
+		if c.Children[name] == nil {
+			errs.add(p, "missing child")
+			continue
+		}
+		errs.date(p + ".birth_date", c.Children[name].BirthDate, true)
+	}
+	for _, name := range sortedKeys(c.Debts) {
+		p := joinPath(path, "debts." + name)
+		if c.Debts[name] == nil {
+			errs.add(p, "missing debt")
+			continue
+		}
+		errs.balance(p + ".principal", c.Debts[name].Principal)
+		errs.dueDate(p + ".due_date", c.Debts[name].DueDate, true, start)
+	}
+	if c.Assets == nil {
+		errs.add(joinPath(path, "assets"), "missing assets")
+		return
+	}
+	c.Assets.validate(errs, joinPath(path, "assets"), start)
+	if c.K401Plan != nil {
+		c.K401Plan.validate(errs, joinPath(path, "401k_plan"), c.Assets)
+	}
+}
+
+func (c *SimConfig) validateSocialSecurity(errs *ConfigErrors, path string, start time.Time) {
+	if c.EarningsRecord == nil {
+		return
+	}
+	p := joinPath(path, "earnings_record")
+	if c.SocialSecurityPayouts != [3]float64{} {
+		errs.add(joinPath(path, "social_security_payouts"), "payouts can't be set with an earnings record")
+	}
+	if c.SocialSecurityAge < EarliestClaimingAge || c.SocialSecurityAge > LatestClaimingAge {
+		errs.add(joinPath(path, "social_security_age"), "claiming age %g is not between %g and %g", c.SocialSecurityAge, EarliestClaimingAge, LatestClaimingAge)
+	}
+	for _, year := range sortedKeys(c.EarningsRecord) {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			errs.add(p + "." + year, "%q is not a year", year)
+			continue
+		}
+		if y < 1951 || y >= start.Year() {
+			errs.add(p + "." + year, "earnings must be from 1951 to %d", start.Year() - 1)
+		}
+		if c.EarningsRecord[year] < 0.0 {
+			errs.add(p + "." + year, "earnings %.2f are negative", c.EarningsRecord[year])
+		}
+	}
+}
+
+func (c *EconomyConfig) validate(errs *ConfigErrors, path string) {
+	if c.Model != "" && !contains(EconomyModels, c.Model) {
+		errs.add(path + ".model", "unknown economy model %q, expected one of %s", c.Model, strings.Join(EconomyModels, ", "))
+	}
+	if c.StartYear != 0 {
+		if c.Model != EconomyHistorical {
+			errs.add(path + ".start_year", "only the historical model has a start year")
+		} else if c.StartYear < History[0].Year || c.StartYear > History[len(History) - 1].Year {
+			errs.add(path + ".start_year", "no market history for %d, expected %d to %d", c.StartYear, History[0].Year, History[len(History) - 1].Year)
+		}
+	}
+	if c.BlockLength != 0 {
+		if c.Model != EconomyBootstrap {
+			errs.add(path + ".block_length", "only the bootstrap model has a block length")
+		} else if c.BlockLength < 1 || c.BlockLength > 1200 {
+			errs.add(path + ".block_length", "block length %d is not between 1 and 1200 months", c.BlockLength)
+		}
+	}
+	if c.Regimes != nil {
+		if c.Model != "" && c.Model != EconomyRegime {
+			errs.add(path + ".regimes", "only the regime model has regimes")
+		} else {
+			c.Regimes.validate(errs, path + ".regimes")
+		}
+	}
+	if c.Correlations != nil {
+		if c.Model != "" && c.Model != EconomyRegime {
+			errs.add(path + ".correlations", "only the regime model has correlations; history brings its own")
+		} else {
+			c.Correlations.validate(errs, path + ".correlations")
+		}
+	}
+}
+
+func (c *CorrelationConfig) validate(errs *ConfigErrors, path string) {
+	for _, p := range []struct {
+		name string
+		v float64
+	}{{"stocks_bonds", c.StocksBonds}, {"stocks_cash", c.StocksCash}, {"bonds_cash", c.BondsCash}} {
+		if p.v <= -1.0 || p.v >= 1.0 {
+			errs.add(path + "." + p.name, "correlation %g is not between -1 and 1", p.v)
+			return
+		}
+	}
+	if _, err := cholesky(c.Matrix()); err != nil {
+		errs.add(path, "these correlations can't happen together")
+	}
+}
+
+// validateAccountPortfolio checks an account's own allocation or risk
+// profile.
+func validateAccountPortfolio(errs *ConfigErrors, path string, alloc *AllocationConfig, profile *RiskProfileConfig) {
+	if alloc != nil && profile != nil {
+		errs.add(path, "expected an allocation or a risk_profile, not both")
+	}
+	if alloc != nil {
+		alloc.validate(errs, path + ".allocation", true)
+	}
+	if profile != nil {
+		profile.validate(errs, path + ".risk_profile")
+		if profile.AssetLocation {
+			errs.add(path + ".risk_profile.asset_location", "asset location is for the household's risk profile")
+		}
+	}
+}
+
+func (c *RiskProfileConfig) validate(errs *ConfigErrors, path string) {
+	staged := c.Speculative != nil || c.Aggressive != nil || c.Moderate != nil || c.Conservative != nil
+	styles := 0
+	for _, set := range []bool{staged, len(c.GlidePath) > 0, c.TargetDate != ""} {
+		if set {
+			styles++
+		}
+	}
+	if styles > 1 {
+		errs.add(path, "expected one of the four stages, a glide_path or a target_date, not more than one")
+	}
+	for _, a := range []struct {
+		name string
+		alloc *AllocationConfig
+	}{{"speculative", c.Speculative}, {"aggressive", c.Aggressive}, {"moderate", c.Moderate}, {"conservative", c.Conservative}} {
+		if a.alloc != nil {
+			a.alloc.validate(errs, path + "." + a.name, true)
+		}
+	}
+	if c.AssetLocation {
+		for _, a := range []*AllocationConfig{c.Speculative, c.Aggressive, c.Moderate, c.Conservative} {
+			if a != nil && a.isRisk {
+				errs.add(path + ".asset_location", "asset location needs allocations, not risk multipliers")
+				break
+			}
+		}
+	}
+	ages := map[float64]bool{}
+	for i, p := range c.GlidePath {
+		pp := path + ".glide_path[" + strconv.Itoa(i) + "]"
+		if p == nil || p.Allocation == nil {
+			errs.add(pp, "missing allocation")
+			continue
+		}
+		if ages[p.Age] {
+			errs.add(pp + ".age", "age %g is already on the glide path", p.Age)
+		}
+		ages[p.Age] = true
+		p.Allocation.validate(errs, pp + ".allocation", false)
+	}
+	if c.TargetDate != "" {
+		if _, ok := TargetDateCurves[c.TargetDate]; !ok {
+			errs.add(path + ".target_date", "unknown target date curve %q, expected one of %s", c.TargetDate, strings.Join(sortedKeys(TargetDateCurves), ", "))
+		}
+	}
+	if c.Rebalance != nil {
+		if c.Rebalance.Schedule != "" && !contains(RebalanceSchedules, c.Rebalance.Schedule) {
+			errs.add(path + ".rebalance.schedule", "unknown schedule %q, expected one of %s", c.Rebalance.Schedule, strings.Join(RebalanceSchedules, ", "))
+		}
+		if c.Rebalance.Threshold != 0.0 {
+			if c.Rebalance.Schedule != RebalanceThreshold {
+				errs.add(path + ".rebalance.threshold", "only threshold rebalancing has a threshold")
+			} else if c.Rebalance.Threshold < 0.0 || c.Rebalance.Threshold >= 1.0 {
+				errs.add(path + ".rebalance.threshold", "threshold %g is not between 0 and 1", c.Rebalance.Threshold)
+			}
+		}
+	}
+}
+
+// validate checks the shares add up.  A risk multiplier is only allowed
+// where allocations stood in for one before.
+func (c *AllocationConfig) validate(errs *ConfigErrors, path string, allowRisk bool) {
+	if c.isRisk {
+		if !allowRisk {
+			errs.add(path, "expected an allocation, not a risk multiplier")
+		}
+		return
+	}
+	if c.Stocks < 0.0 || c.Bonds < 0.0 || c.Cash < 0.0 {
+		errs.add(path, "allocation has a negative share")
+	} else if total := c.Stocks + c.Bonds + c.Cash; math.Abs(total - 1.0) > 1e-6 {
+		errs.add(path, "allocation adds up to %.4g, not 1", total)
+	}
+}
+
+func (c *RegimeConfig) validate(errs *ConfigErrors, path string) {
+	names := []string{}
+	if len(c.States) == 0 {
+		preset := DefaultRegimes
+		if c.Preset != "" {
+			var ok bool
+			preset, ok = RegimePresets[c.Preset]
+			if !ok {
+				errs.add(path + ".preset", "unknown preset %q, expected one of %s", c.Preset, strings.Join(sortedKeys(RegimePresets), ", "))
+				return
+			}
+		}
+		if len(c.Transitions) > 0 {
+			errs.add(path + ".transitions", "transitions need states")
+		}
+		names = preset.States()
+	} else {
+		if c.Preset != "" {
+			errs.add(path + ".preset", "a preset can't be combined with states")
+		}
+		for i, s := range c.States {
+			p := path + ".states[" + strconv.Itoa(i) + "]"
+			if s == nil {
+				errs.add(p, "missing state")
+				continue
+			}
+			if s.Name == "" {
+				errs.add(p + ".name", "missing name")
+			} else if contains(names, s.Name) {
+				errs.add(p + ".name", "duplicate state %q", s.Name)
+			}
+			names = append(names, s.Name)
+			s.validate(errs, p)
+		}
+		if len(c.Transitions) != len(c.States) {
+			errs.add(path + ".transitions", "expected %d rows, one for each state, found %d", len(c.States), len(c.Transitions))
+		}
+		for i, row := range c.Transitions {
+			p := path + ".transitions[" + strconv.Itoa(i) + "]"
+			if len(row) != len(c.States) {
+				errs.add(p, "expected %d probabilities, one for each state, found %d", len(c.States), len(row))
+				continue
+			}
+			var total float64 = 0.0
+			for j, v := range row {
+				if v < 0.0 {
+					errs.add(p + "[" + strconv.Itoa(j) + "]", "probability %g is negative", v)
+				}
+				total += v
+			}
+			if math.Abs(total - 1.0) > 1e-6 {
+				errs.add(p, "probabilities add up to %g, not 1", total)
+			}
+		}
+	}
+	if c.Start != "" && !contains(names, c.Start) {
+		errs.add(path + ".start", "unknown state %q, expected one of %s", c.Start, strings.Join(names, ", "))
+	}
+}
+
+func (s *RegimeStateConfig) validate(errs *ConfigErrors, path string) {
+	if s.Duration == nil {
+		errs.add(path + ".duration", "missing duration")
+	} else if min, _ := s.Duration.Bounds(); min < 1.0 {
+		errs.add(path + ".duration", "duration needs a min of at least 1 month")
+	}
+	if s.MeanReturn == nil {
+		errs.add(path + ".mean_return", "missing mean return")
+	}
+	if s.Volatility == nil {
+		errs.add(path + ".volatility", "missing volatility")
+	} else if min, _ := s.Volatility.Bounds(); min < 0.0 {
+		errs.add(path + ".volatility", "volatility needs a min of at least 0")
+	}
+	if s.BondVolatility != nil {
+		if min, _ := s.BondVolatility.Bounds(); min < 0.0 {
+			errs.add(path + ".bond_volatility", "volatility needs a min of at least 0")
+		}
+	}
+	if s.BillVolatility != nil {
+		if min, _ := s.BillVolatility.Bounds(); min < 0.0 {
+			errs.add(path + ".bill_volatility", "volatility needs a min of at least 0")
+		}
+	}
+	for _, d := range []struct {
+		name string
+		dist *DistributionConfig
+	}{
+		{"duration", s.Duration},
+		{"mean_return", s.MeanReturn},
+		{"volatility", s.Volatility},
+		{"bond_return", s.BondReturn},
+		{"bond_volatility", s.BondVolatility},
+		{"bill_return", s.BillReturn},
+		{"bill_volatility", s.BillVolatility},
+	} {
+		if d.dist == nil {
+			continue
+		}
+		min, max := d.dist.Bounds()
+		if min > max {
+			errs.add(path + "." + d.name, "min %g is above max %g", min, max)
+		}
+		if d.dist.StdDev < 0.0 {
+			errs.add(path + "." + d.name, "stddev %g is negative", d.dist.StdDev)
+		}
+	}
+}
+
+func (c *SpendingConfig) validate(errs *ConfigErrors, path string) {
+	if c.Policy != "" && !contains(SpendingPolicies, c.Policy) {
+		errs.add(path + ".policy", "unknown spending policy %q, expected one of %s", c.Policy, strings.Join(SpendingPolicies, ", "))
+	}
+	if c.Rate < 0.0 || c.Rate >= 1.0 {
+		errs.add(path + ".rate", "rate %g is not between 0 and 1", c.Rate)
+	}
+	if c.Floor < 0.0 || c.Floor > 1.0 {
+		errs.add(path + ".floor", "floor %g is not between 0 and 1", c.Floor)
+	}
+	if c.Ceiling != 0.0 && c.Ceiling < 1.0 {
+		errs.add(path + ".ceiling", "ceiling %g is below 1", c.Ceiling)
+	}
+}
+
+func (c *K401PlanConfig) validate(errs *ConfigErrors, path string, assets *AssetConfig) {
+	if c.Roth {
+		if _, ok := assets.Roth401K[c.Account]; !ok {
+			errs.add(path + ".account", "no Roth 401k named %q in assets", c.Account)
+		}
+	} else if _, ok := assets.K401[c.Account]; !ok {
+		errs.add(path + ".account", "no 401k named %q in assets", c.Account)
+	}
+	if c.Deferral < 0.0 || c.Deferral > 1.0 {
+		errs.add(path + ".deferral", "deferral %g is not between 0 and 1", c.Deferral)
+	}
+	var prev float64 = 0.0
+	for i, m := range c.Match {
+		p := path + ".match[" + strconv.Itoa(i) + "]"
+		if m == nil {
+			errs.add(p, "missing match tier")
+			continue
+		}
+		if m.Rate < 0.0 {
+			errs.add(p + ".rate", "match rate %g is negative", m.Rate)
+		}
+		if m.UpTo <= prev || m.UpTo > 1.0 {
+			errs.add(p + ".up_to", "match tiers must increase between 0 and 1")
+		}
+		prev = m.UpTo
+	}
+}
+
+func (c *AssetConfig) validate(errs *ConfigErrors, path string, start time.Time) {
+	errs.balance(path + ".cash", c.Cash)
+	for _, name := range sortedKeys(c.SlushFund) {
+		p := path + ".slush_fund." + name
+		if c.SlushFund[name] == nil {
+			errs.add(p, "missing account")
+			continue
+		}
+		errs.balance(p + ".balance", c.SlushFund[name].Balance)
+		validateAccountPortfolio(errs, p, c.SlushFund[name].Allocation, c.SlushFund[name].RiskProfile)
+	}
+	for _, name := range sortedKeys(c.K401) {
+		p := path + ".401k." + name
+		if c.K401[name] == nil {
+			errs.add(p, "missing account")
+			continue
+		}
+		errs.balance(p + ".balance", c.K401[name].Balance)
+		validateAccountPortfolio(errs, p, c.K401[name].Allocation, c.K401[name].RiskProfile)
+	}
+	for _, name := range sortedKeys(c.IRA) {
+		p := path + ".ira." + name
+		if c.IRA[name] == nil {
+			errs.add(p, "missing account")
+			continue
+		}
+		errs.balance(p + ".balance", c.IRA[name].Balance)
+		validateAccountPortfolio(errs, p, c.IRA[name].Allocation, c.IRA[name].RiskProfile)
+		switch c.IRA[name].Type {
+		case "", IRAInherited:
+			errs.date(p + ".inherit_date", c.IRA[name].InheritDate, false)
+			errs.date(p + ".owner_birth_date", c.IRA[name].OwnerBirthDate, false)
+			if c.IRA[name].SEPPAge != 0.0 {
+				errs.add(p + ".sepp_age", "72(t) payments are only for traditional IRAs")
+			}
+		case IRATraditional:
+			if c.IRA[name].InheritDate != "" {
+				errs.add(p + ".inherit_date", "traditional IRAs aren't inherited")
+			}
+			if c.IRA[name].OwnerBirthDate != "" {
+				errs.add(p + ".owner_birth_date", "traditional IRAs aren't inherited")
+			}
+			if c.IRA[name].SEPPAge < 0.0 || c.IRA[name].SEPPAge >= EarlyWithdrawalAge {
+				errs.add(p + ".sepp_age", "72(t) payments must start before age %g", EarlyWithdrawalAge)
+			}
+		default:
+			errs.add(p + ".type", "unknown IRA type %q", c.IRA[name].Type)
+		}
+	}
+	c.validateRoth(errs, path + ".roth_ira", c.RothIRA)
+	c.validateRoth(errs, path + ".roth_401k", c.Roth401K)
+	if c.Home != nil {
+		errs.balance(path + ".home.value", c.Home.Value)
+		errs.balance(path + ".home.principal", c.Home.Principal)
+		errs.dueDate(path + ".home.due_date", c.Home.DueDate, false, start)
+	}
+	if c.Car != nil {
+		errs.balance(path + ".car.price", c.Car.Price)
+		errs.balance(path + ".car.principal", c.Car.Principal)
+		errs.date(path + ".car.purchase_date", c.Car.PurchaseDate, false)
+		errs.dueDate(path + ".car.due_date", c.Car.DueDate, false, start)
+	}
+}
+
+func (c *AssetConfig) validateRoth(errs *ConfigErrors, path string, accts map[string]*RothConfig) {
+	for _, name := range sortedKeys(accts) {
+		p := path + "." + name
+		if accts[name] == nil {
+			errs.add(p, "missing account")
+			continue
+		}
+		errs.balance(p + ".balance", accts[name].Balance)
+		errs.balance(p + ".contributions", accts[name].Contributions)
+		errs.date(p + ".open_date", accts[name].OpenDate, false)
+		validateAccountPortfolio(errs, p, accts[name].Allocation, accts[name].RiskProfile)
+	}
+}
@@ -0,0 +1,209 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	WithdrawDefault = "default"
+	WithdrawTaxableFirst = "taxable_first"
+	WithdrawTaxDeferredFirst = "tax_deferred_first"
+	WithdrawProportional = "proportional"
+	WithdrawFill12 = "fill_12_bracket"
+)
+
+// WithdrawalStrategies lists the strategies withdrawal_strategy can
+// name.
+var WithdrawalStrategies = []string{
+	WithdrawDefault,
+	WithdrawTaxableFirst,
+	WithdrawTaxDeferredFirst,
+	WithdrawProportional,
+	WithdrawFill12,
+}
+
+// WithdrawalStrategy decides which investments are sold when the cash
+// account drops below the cushion.
+type WithdrawalStrategy interface {
+	TopOff(date time.Time)
+}
+
+func NewWithdrawalStrategy(sim *Simulation, name string) WithdrawalStrategy {
+	switch name {
+	case WithdrawTaxableFirst:
+		return &OrderedWithdrawals{
+			Simulacrum: NewSimulacrum(sim),
+			order: []AccountFilter{Brokerage, TaxDeferred, TaxFree},
+		}
+	case WithdrawTaxDeferredFirst:
+		return &OrderedWithdrawals{
+			Simulacrum: NewSimulacrum(sim),
+			order: []AccountFilter{TaxDeferred, Brokerage, TaxFree},
+		}
+	case WithdrawProportional:
+		return &ProportionalWithdrawals{Simulacrum: NewSimulacrum(sim)}
+	case WithdrawFill12:
+		return &BracketWithdrawals{Simulacrum: NewSimulacrum(sim), rate: 0.12}
+	}
+	return &OrderedWithdrawals{
+		Simulacrum: NewSimulacrum(sim),
+		order: []AccountFilter{Untaxed, AnyAccount},
+	}
+}
+
+// AccountFilter picks out one kind of investment account.
+type AccountFilter func(acct *InvestmentAccount) bool
+
+func AnyAccount(acct *InvestmentAccount) bool {
+	return true
+}
+
+// Untaxed accounts don't add to ordinary income when drawn from.
+func Untaxed(acct *InvestmentAccount) bool {
+	return !acct.Taxable()
+}
+
+// Brokerage accounts are taxed on their gains.
+func Brokerage(acct *InvestmentAccount) bool {
+	return !acct.Taxable() && !acct.TaxFree()
+}
+
+// TaxDeferred accounts are taxed as ordinary income when drawn from.
+func TaxDeferred(acct *InvestmentAccount) bool {
+	return acct.Taxable()
+}
+
+func TaxFree(acct *InvestmentAccount) bool {
+	return acct.TaxFree()
+}
+
+// shortfall returns how much it takes to bring cash back up to the
+// cushion, or 0 if cash hasn't fallen far enough to need it.
+func (s *Simulation) shortfall() float64 {
+	bal := s.CashAccount.Balance()
+	if bal >= s.config.Cushion * 0.75 {
+		return 0.0
+	}
+	return s.config.Cushion - bal
+}
+
+// drawRMDs tops off cash from the accounts with required distributions
+// still due this year, largest first, since that money has to come out
+// anyway.
+func drawRMDs(sim *Simulation, date time.Time) {
+	for sim.shortfall() > 0.0 {
+		tgt := sim.shortfall()
+		var maxRmd float64
+		var maxRmdAcct *InvestmentAccount
+		for _, acct := range sim.Investments {
+			rmd := acct.RMD(date)
+			if rmd > 0.0 {
+				if maxRmdAcct == nil || rmd > maxRmd {
+					maxRmd = rmd
+					maxRmdAcct = acct
+				}
+			}
+		}
+		if maxRmdAcct == nil {
+			break
+		}
+		if tgt > maxRmd {
+			tgt = maxRmd
+		}
+		maxRmdAcct.Withdraw(tgt, date, "Cushion")
+	}
+}
+
+// drawFrom tops off cash from the first account that matches and still
+// has money, moving on to the next when one runs dry.  max caps each
+// withdrawal; it returns 0 once nothing more should be drawn.
+func drawFrom(sim *Simulation, date time.Time, match AccountFilter, max func() float64) {
+	for sim.shortfall() > 0.0 {
+		tgt := sim.shortfall()
+		if max != nil {
+			tgt = math.Min(tgt, max())
+			if tgt <= 0.0 {
+				return
+			}
+		}
+		found := false
+		for _, acct := range sim.Investments {
+			if !acct.CanWithdraw(date) || !match(acct) {
+				continue
+			}
+			if acct.Balance() > 0.0 {
+				found = true
+				acct.Withdraw(tgt, date, "Cushion")
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// OrderedWithdrawals draws from one kind of account after another.  The
+// default order, untaxed accounts and then anything, is the order the
+// simulation has always used.
+type OrderedWithdrawals struct {
+	*Simulacrum
+	order []AccountFilter
+}
+
+func (w *OrderedWithdrawals) TopOff(date time.Time) {
+	drawRMDs(w.Sim(), date)
+	for _, match := range w.order {
+		drawFrom(w.Sim(), date, match, nil)
+	}
+}
+
+// ProportionalWithdrawals draws from every account in proportion to its
+// balance.
+type ProportionalWithdrawals struct {
+	*Simulacrum
+}
+
+func (w *ProportionalWithdrawals) TopOff(date time.Time) {
+	sim := w.Sim()
+	drawRMDs(sim, date)
+	for sim.shortfall() > 0.0 {
+		tgt := sim.shortfall()
+		var total float64 = 0.0
+		for _, acct := range sim.Investments {
+			if acct.CanWithdraw(date) && acct.Balance() > 0.0 {
+				total += acct.Balance()
+			}
+		}
+		if total <= 0.0 {
+			return
+		}
+		for _, acct := range sim.Investments {
+			if acct.CanWithdraw(date) && acct.Balance() > 0.0 {
+				acct.Withdraw(tgt * acct.Balance() / total, date, "Cushion")
+			}
+		}
+	}
+}
+
+// BracketWithdrawals draws from tax-deferred accounts only until the
+// year's ordinary income fills the bracket taxed at rate, then from
+// brokerage accounts, then Roth accounts, and only then from tax-deferred
+// accounts again.  Income is measured as earned so far this year.
+type BracketWithdrawals struct {
+	*Simulacrum
+	rate float64
+}
+
+func (w *BracketWithdrawals) TopOff(date time.Time) {
+	sim := w.Sim()
+	drawRMDs(sim, date)
+	room := func() float64 {
+		return sim.TaxMen.Federal.BracketRoom(w.rate, date.Year())
+	}
+	drawFrom(sim, date, TaxDeferred, room)
+	drawFrom(sim, date, Brokerage, nil)
+	drawFrom(sim, date, TaxFree, nil)
+	drawFrom(sim, date, TaxDeferred, nil)
+}
@@ -315,6 +315,8 @@ Retirement.prototype.balances = function(i) {
   Plotly.d3.csv('go/results/balances-'+n+'.csv', function(balances) {
     var first = Object.assign({}, balances[0]);
     delete(first.date);
+    delete(first.price_index);
+    delete(first.spending);
     var series = Object.keys(first);
     series.sort();
     var data = series.map(function(k, i) {